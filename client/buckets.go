@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+)
+
+// BucketRequest is the body of a new or updated bucket
+type BucketRequest struct {
+	Name    string   `json:"name,omitempty"`
+	Pubkeys []string `json:"pubkeys,omitempty"`
+}
+
+// TokenRequest asks for a token to push a frame to or pull a file from a bucket
+type TokenRequest struct {
+	Operation string `json:"operation"`
+	File      string `json:"file,omitempty"`
+	Frame     string `json:"frame,omitempty"`
+}
+
+// ListBuckets returns the user's buckets
+func (c *Client) ListBuckets(ctx context.Context) ([]Bucket, error) {
+	var buckets []Bucket
+	if err := c.do(ctx, "GET", "/buckets", nil, nil, &buckets); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// GetBucket returns the bucket with the ID
+func (c *Client) GetBucket(ctx context.Context, id string) (*Bucket, error) {
+	b := &Bucket{}
+	if err := c.do(ctx, "GET", "/buckets/"+escape(id), nil, nil, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// GetBucketByName returns the user's bucket with the name
+func (c *Client) GetBucketByName(ctx context.Context, name string) (*Bucket, error) {
+	b := &Bucket{}
+	if err := c.do(ctx, "GET", "/bucket-ids/"+escape(name), nil, nil, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// CreateBucket creates a bucket
+func (c *Client) CreateBucket(ctx context.Context, r BucketRequest) (*Bucket, error) {
+	b := &Bucket{}
+	if err := c.do(ctx, "POST", "/buckets", nil, r, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// UpdateBucket changes the name or public keys of a bucket
+func (c *Client) UpdateBucket(ctx context.Context, id string, r BucketRequest) (*Bucket, error) {
+	b := &Bucket{}
+	if err := c.do(ctx, "PATCH", "/buckets/"+escape(id), nil, r, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DeleteBucket deletes a bucket and its files
+func (c *Client) DeleteBucket(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/buckets/"+escape(id), nil, nil, nil)
+}
+
+// CreateToken returns a token for transferring data to or from a bucket
+func (c *Client) CreateToken(ctx context.Context, bucket string, r TokenRequest) (*Token, error) {
+	t := &Token{}
+	if err := c.do(ctx, "POST", "/buckets/"+escape(bucket)+"/tokens", nil, r, t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
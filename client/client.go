@@ -0,0 +1,238 @@
+// Package client calls the bridge API. Requests are authenticated with basic auth, signed with
+// a secp256k1 key, or both, and idempotent requests are retried when the bridge is briefly
+// unavailable.
+//
+// The bridge does not yet verify signatures, so a key is not a substitute for basic auth: the
+// X-PubKey and X-Signature headers are sent but currently unchecked.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetries is how many times a failed request is retried unless Client.Retries is changed
+const DefaultRetries = 3
+
+// DefaultBackoff is the wait before the first retry. It doubles with each attempt.
+const DefaultBackoff = 500 * time.Millisecond
+
+// DefaultMaxRetryAfter is the longest Retry-After a request waits for unless
+// Client.MaxRetryAfter is changed
+const DefaultMaxRetryAfter = 10 * time.Second
+
+// Client calls the API of one bridge
+type Client struct {
+	baseURL  *url.URL
+	email    string
+	password string
+	key      *KeyPair
+
+	// HTTPClient sends the requests. It defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+	// Retries is the number of times a GET, PUT, or DELETE is retried after a network error, a
+	// 429, or a 5xx response. Other methods are never retried, so they are not applied twice.
+	Retries int
+	// Backoff is the wait before the first retry. A Retry-After header overrides it.
+	Backoff time.Duration
+	// MaxRetryAfter is the longest Retry-After the client waits for. A 429 asking for a longer
+	// wait is returned to the caller instead.
+	MaxRetryAfter time.Duration
+	// TOTPCode is sent with every request when set, for users with two-factor enabled. Password
+	// resets need it even though they are not otherwise authenticated.
+	TOTPCode string
+}
+
+// New returns a Client for the bridge at baseURL, such as https://api.storj.io
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		baseURL:       u,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+		Retries:       DefaultRetries,
+		Backoff:       DefaultBackoff,
+		MaxRetryAfter: DefaultMaxRetryAfter,
+	}, nil
+}
+
+// SetBasicAuth authenticates requests as the user with the provided email and plain text
+// password. Only the password's hash is sent.
+func (c *Client) SetBasicAuth(email, password string) {
+	c.email = email
+	c.password = HashPassword(password)
+}
+
+// SetBasicAuthHash authenticates requests as the user with a password hash from HashPassword,
+// so that callers can store credentials without keeping the password
+func (c *Client) SetBasicAuthHash(email, hash string) {
+	c.email = email
+	c.password = hash
+}
+
+// SetKey signs every request with the key pair. The bridge does not check signatures yet, so
+// requests still need basic auth.
+func (c *Client) SetKey(k *KeyPair) {
+	c.key = k
+}
+
+// HashPassword returns the hex SHA-256 of the password, which is what the bridge expects in
+// place of the password itself
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// do sends the request, retrying as configured, and decodes the response into out. If out is
+// an io.Writer the body is copied to it instead.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, query, body)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.Retries || !idempotent(method) {
+				return err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		wait := time.Duration(retryAfter) * time.Second
+		if attempt < c.Retries && retryable(method, resp.StatusCode) && wait <= c.MaxRetryAfter {
+			drain(resp)
+			if err := c.wait(ctx, attempt, wait); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return decode(resp, out)
+	}
+}
+
+// send builds, authenticates, and sends a single request. The path's segments are already
+// escaped.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *c.baseURL
+	u.RawPath = c.baseURL.EscapedPath() + path
+	u.Path += unescaped
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.email != "" {
+		req.SetBasicAuth(c.email, c.password)
+	}
+	if c.TOTPCode != "" {
+		req.Header.Set(TOTPHeader, c.TOTPCode)
+	}
+
+	if c.key != nil {
+		payload := body
+		if body == nil {
+			payload = []byte(u.RawQuery)
+		}
+		req.Header.Set(PubKeyHeader, c.key.PublicHex())
+		req.Header.Set(SignatureHeader, c.key.Sign(method, u.Path, payload))
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+// wait sleeps before the next attempt for at least min, backing off exponentially
+func (c *Client) wait(ctx context.Context, attempt int, min time.Duration) error {
+	d := time.Duration(float64(c.Backoff) * math.Pow(2, float64(attempt)))
+	if min > d {
+		d = min
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// idempotent reports whether repeating the method cannot apply a change twice
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+}
+
+// retryable reports whether a response means the request can be sent again
+func retryable(method string, status int) bool {
+	if !idempotent(method) {
+		return false
+	}
+
+	return status == http.StatusTooManyRequests || status >= 500 && status != http.StatusNotImplemented
+}
+
+// decode reads a successful response into out or returns the error it describes
+func decode(resp *http.Response, out interface{}) error {
+	defer drain(resp)
+
+	if resp.StatusCode >= 400 {
+		return newError(resp)
+	}
+
+	if w, ok := out.(io.Writer); ok {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// drain reads the rest of the body so the connection can be reused, then closes it
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// escape encodes a value for use as one path segment
+func escape(s string) string {
+	return url.PathEscape(s)
+}
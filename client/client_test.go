@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetries(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		statuses   []int
+		retryAfter string
+		attempts   int
+		status     int
+	}{
+		{name: "get retried on 503", method: "GET", statuses: []int{503, 503, 200}, attempts: 3, status: 200},
+		{name: "post not retried on 500", method: "POST", statuses: []int{500, 200}, attempts: 1, status: 500},
+		{name: "get retried on 429", method: "GET", statuses: []int{429, 200}, retryAfter: "0", attempts: 2, status: 200},
+		{name: "post not retried on 429", method: "POST", statuses: []int{429, 201}, retryAfter: "0", attempts: 1, status: 429},
+		{name: "long retry-after not waited for", method: "GET", statuses: []int{429, 200}, retryAfter: "3600", attempts: 1, status: 429},
+		{name: "retries exhausted", method: "DELETE", statuses: []int{502, 502, 502, 502, 502}, attempts: 4, status: 502},
+		{name: "client errors not retried", method: "GET", statuses: []int{404, 200}, attempts: 1, status: 404},
+	}
+
+	for _, c := range cases {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := c.statuses[attempts]
+			attempts++
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", c.retryAfter)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{}`))
+		}))
+
+		client, err := New(srv.URL)
+		assert.NoError(t, err)
+		client.Backoff = time.Millisecond
+
+		err = client.do(context.Background(), c.method, "/", nil, nil, nil)
+		srv.Close()
+
+		assert.Equal(t, c.attempts, attempts, c.name)
+		if c.status < 400 {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.Equal(t, c.status, StatusCode(err), c.name)
+		}
+	}
+}
+
+func TestErrorDecoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		expected Error
+	}{
+		{
+			name:     "bridge error",
+			body:     `{"error":"not_found","message":"bucket not found"}`,
+			expected: Error{StatusCode: 404, Code: "not_found", Message: "bucket not found", RequestID: "req-1"},
+		},
+		{
+			name:     "proxy error",
+			body:     `<html>Not Found</html>`,
+			expected: Error{StatusCode: 404, Code: "http_error", Message: "Not Found", RequestID: "req-1"},
+		},
+	}
+
+	for _, c := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-1")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(c.body))
+		}))
+
+		client, _ := New(srv.URL)
+		_, err := client.GetBucket(context.Background(), "b1")
+		srv.Close()
+
+		if assert.IsType(t, &Error{}, err, c.name) {
+			assert.Equal(t, c.expected, *err.(*Error), c.name)
+		}
+		assert.True(t, IsNotFound(err), c.name)
+	}
+}
+
+func TestPathEscaping(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		json.NewEncoder(w).Encode(Bucket{ID: "b1"})
+	}))
+	defer srv.Close()
+
+	client, _ := New(srv.URL + "/api")
+	_, err := client.GetBucketByName(context.Background(), "my bucket/100%")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/api/bucket-ids/my%20bucket%2F100%25", got.URL.EscapedPath())
+	assert.Equal(t, "/api/bucket-ids/my bucket/100%", got.URL.Path)
+}
+
+func TestAuthentication(t *testing.T) {
+	key := GenerateKey()
+
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Token{ID: "t1", Bucket: "b1", Operation: OperationPush})
+	}))
+	defer srv.Close()
+
+	client, _ := New(srv.URL + "/")
+	client.SetBasicAuth("user@storj.io", "password")
+	client.SetKey(key)
+	client.TOTPCode = "123456"
+
+	token, err := client.CreateToken(context.Background(), "b1", TokenRequest{Operation: OperationPush, Frame: "f1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", token.ID)
+
+	email, password, ok := got.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user@storj.io", email)
+	assert.Equal(t, HashPassword("password"), password)
+	assert.Equal(t, "123456", got.Header.Get(TOTPHeader))
+
+	assert.Equal(t, "/buckets/b1/tokens", got.URL.Path)
+	assert.JSONEq(t, `{"operation":"PUSH","frame":"f1"}`, string(body))
+	assert.Equal(t, key.PublicHex(), got.Header.Get(PubKeyHeader))
+	assert.True(t, Verify(got.Header.Get(PubKeyHeader), got.Header.Get(SignatureHeader), "POST", got.URL.Path, body))
+	assert.False(t, Verify(got.Header.Get(PubKeyHeader), got.Header.Get(SignatureHeader), "POST", "/buckets/b2/tokens", body))
+}
+
+func TestTOTPWithoutBasicAuth(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		json.NewEncoder(w).Encode(User{UUID: "u1"})
+	}))
+	defer srv.Close()
+
+	client, _ := New(srv.URL)
+	client.TOTPCode = "123456"
+
+	_, err := client.ConfirmPasswordReset(context.Background(), "t1", "password")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/resets/t1", got.URL.Path)
+	assert.Equal(t, "123456", got.Header.Get(TOTPHeader), "two-factor users reset without credentials")
+	_, _, ok := got.BasicAuth()
+	assert.False(t, ok)
+}
+
+func TestParseKey(t *testing.T) {
+	key := GenerateKey()
+
+	parsed, err := ParseKey(key.PrivateHex())
+	assert.NoError(t, err)
+	assert.Equal(t, key.PublicHex(), parsed.PublicHex())
+
+	for _, invalid := range []string{"", "zz", "00", "0000000000000000000000000000000000000000000000000000000000000000"} {
+		_, err := ParseKey(invalid)
+		assert.Equal(t, ErrInvalidKey, err, invalid)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coyle/bridge/client"
+)
+
+// register creates an account, generating a key for it if the keyring has none, and saves
+// the credentials so later commands are authenticated
+func register(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	email := fs.String("email", "", "email address of the new account")
+	referral := fs.String("referral", "", "referral partner ID")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	password, err := e.password()
+	if err != nil {
+		return err
+	}
+
+	key, err := e.keyring.key()
+	if err != nil {
+		return fmt.Errorf("keyring: %v", err)
+	}
+	if key == nil {
+		key = client.GenerateKey()
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	err = c.Register(ctx, client.Registration{
+		Email:           *email,
+		Password:        password,
+		PublicKey:       key.PublicHex(),
+		ReferralPartner: *referral,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.keyring.URL = e.url
+	e.keyring.Email = *email
+	e.keyring.PasswordHash = client.HashPassword(password)
+	e.keyring.PrivateKey = key.PrivateHex()
+	if err := e.keyring.save(e.keyringPath); err != nil {
+		return fmt.Errorf("keyring: %v", err)
+	}
+
+	fmt.Fprintf(e.stderr, "Registered %s. Follow the link emailed to you to activate the account.\n", *email)
+	return nil
+}
+
+// login saves the credentials of an existing account. They are checked by the first command
+// that needs them, since the bridge has no endpoint that only authenticates.
+func login(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	email := fs.String("email", "", "email address of the account")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	password, err := e.password()
+	if err != nil {
+		return err
+	}
+
+	e.keyring.URL = e.url
+	e.keyring.Email = *email
+	e.keyring.PasswordHash = client.HashPassword(password)
+	if err := e.keyring.save(e.keyringPath); err != nil {
+		return fmt.Errorf("keyring: %v", err)
+	}
+
+	fmt.Fprintf(e.stderr, "Saved the credentials of %s to %s\n", *email, e.keyringPath)
+	return nil
+}
+
+// keygen saves a new key pair to the keyring and prints the public key
+func keygen(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	force := fs.Bool("force", false, "replace the key already in the keyring")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if e.keyring.PrivateKey != "" && !*force {
+		return errors.New("the keyring already has a key, use -force to replace it")
+	}
+
+	key := client.GenerateKey()
+	e.keyring.PrivateKey = key.PrivateHex()
+	if err := e.keyring.save(e.keyringPath); err != nil {
+		return fmt.Errorf("keyring: %v", err)
+	}
+
+	return e.print(map[string]string{"pubkey": key.PublicHex()}, []interface{}{key.PublicHex()})
+}
+
+// listBuckets prints the ID, name, and status of each bucket
+func listBuckets(ctx context.Context, e *env, args []string) error {
+	if err := parse(e.flags(), args, 0); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	buckets, err := c.ListBuckets(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, len(buckets))
+	for i, b := range buckets {
+		rows[i] = []interface{}{b.ID, b.Name, b.Status}
+	}
+
+	return e.print(buckets, rows...)
+}
+
+// addBucket creates a bucket that accepts requests signed with the keyring's key
+func addBucket(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	r := client.BucketRequest{Name: fs.Arg(0)}
+	if key, _ := e.keyring.key(); key != nil {
+		r.Pubkeys = []string{key.PublicHex()}
+	}
+
+	b, err := c.CreateBucket(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return e.print(b, []interface{}{b.ID, b.Name, b.Status})
+}
+
+// listFiles prints the ID, name, MIME type, and creation time of each file in a bucket
+func listFiles(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	files, err := c.ListFiles(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, len(files))
+	for i, f := range files {
+		rows[i] = fileRow(&f)
+	}
+
+	return e.print(files, rows...)
+}
+
+// authorizeUpload stages a frame and authorizes pushing shards to it. The bridge only records
+// where shards are; sending them to farmers with the printed token is left to a storage client,
+// after which add-file adds the frame to the bucket.
+func authorizeUpload(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	frame, err := c.CreateFrame(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := c.CreateToken(ctx, fs.Arg(0), client.TokenRequest{Operation: client.OperationPush, Frame: frame.ID})
+	if err != nil {
+		return err
+	}
+
+	return e.print(upload{Frame: frame.ID, Token: token}, []interface{}{frame.ID, token.ID})
+}
+
+// addFile adds a frame whose shards have been pushed to a bucket as a file
+func addFile(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	mimeType := fs.String("mimetype", "", "MIME type of the file, by default guessed from its extension")
+	if err := parse(fs, args, 3); err != nil {
+		return err
+	}
+	bucket, frame, name := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	file, err := c.CreateFile(ctx, bucket, client.FileRequest{
+		Frame:    frame,
+		Filename: name,
+		MimeType: or(*mimeType, mime.TypeByExtension(filepath.Ext(name))),
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.print(file, fileRow(file))
+}
+
+// authorizeDownload authorizes pulling a file's shards and prints its metadata with the token
+func authorizeDownload(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	bucket, id := fs.Arg(0), fs.Arg(1)
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	file, err := c.FileInfo(ctx, bucket, id)
+	if err != nil {
+		return err
+	}
+	token, err := c.CreateToken(ctx, bucket, client.TokenRequest{Operation: client.OperationPull, File: file.ID})
+	if err != nil {
+		return err
+	}
+
+	return e.print(download{File: file, Token: token}, append(fileRow(file), token.ID))
+}
+
+// generateToken creates a token for pushing a frame to or pulling a file from a bucket
+func generateToken(ctx context.Context, e *env, args []string) error {
+	fs := e.flags()
+	operation := fs.String("operation", "", "push or pull")
+	file := fs.String("file", "", "ID of the file to pull")
+	frame := fs.String("frame", "", "ID of the frame to push")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if *operation != client.OperationPush && *operation != client.OperationPull {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	token, err := c.CreateToken(ctx, fs.Arg(0), client.TokenRequest{Operation: *operation, File: *file, Frame: *frame})
+	if err != nil {
+		return err
+	}
+
+	return e.print(token, []interface{}{token.ID, token.Operation, token.Expires.Format(time.RFC3339)})
+}
+
+// upload is printed by authorize-upload
+type upload struct {
+	Frame string        `json:"frame"`
+	Token *client.Token `json:"token"`
+}
+
+// download is printed by authorize-download
+type download struct {
+	File  *client.File  `json:"file"`
+	Token *client.Token `json:"token"`
+}
+
+// fileRow returns the columns printed for a file
+func fileRow(f *client.File) []interface{} {
+	return []interface{}{f.ID, f.Name, f.MimeType, f.Created.Format(time.RFC3339)}
+}
+
+// password returns BRIDGE_PASSWORD or else prompts for the password and reads it from stdin,
+// without echoing it if stdin is a terminal
+func (e *env) password() (string, error) {
+	if p := e.getenv("BRIDGE_PASSWORD"); p != "" {
+		return p, nil
+	}
+
+	fmt.Fprint(e.stderr, "Password: ")
+	if restore := noEcho(e.stdin); restore != nil {
+		defer func() {
+			restore()
+			fmt.Fprintln(e.stderr)
+		}()
+	}
+	line, err := bufio.NewReader(e.stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// noEcho turns off echo on the terminal r reads from and returns a function restoring it. It
+// returns nil if r is not a terminal or echo could not be turned off.
+func noEcho(r io.Reader) func() {
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil
+	}
+	if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = f
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		return nil
+	}
+
+	return func() { stty("echo") }
+}
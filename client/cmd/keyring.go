@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coyle/bridge/client"
+)
+
+// Keyring holds the credentials the CLI authenticates with. It stores the password hash the
+// bridge expects rather than the password, and the private key used to sign requests.
+type Keyring struct {
+	URL          string `json:"url,omitempty"`
+	Email        string `json:"email,omitempty"`
+	PasswordHash string `json:"passwordHash,omitempty"`
+	PrivateKey   string `json:"privateKey,omitempty"`
+}
+
+// defaultKeyring returns the keyring path under the user's home directory
+func defaultKeyring(getenv func(string) string) string {
+	return filepath.Join(getenv("HOME"), ".bridge", "keyring.json")
+}
+
+// loadKeyring reads the keyring at path. A missing keyring is empty rather than an error.
+func loadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return k, json.Unmarshal(b, k)
+}
+
+// save writes the keyring to path, readable only by the current user
+func (k *Keyring) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// key returns the stored key pair, or nil if none has been generated
+func (k *Keyring) key() (*client.KeyPair, error) {
+	if k.PrivateKey == "" {
+		return nil, nil
+	}
+
+	return client.ParseKey(k.PrivateKey)
+}
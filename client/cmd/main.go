@@ -0,0 +1,215 @@
+// Command bridge calls the bridge API from the command line. Credentials and the signing key
+// are kept in a local keyring so that later commands can be scripted without prompting. Build
+// it with go build -o bridge.
+//
+// Usage:
+//
+//	bridge [-url url] [-keyring path] [-totp code] [-json] <command> [flags] [args]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/coyle/bridge/client"
+)
+
+// defaultURL is the bridge used when neither a flag, the environment, nor the keyring names one
+const defaultURL = "https://api.storj.io"
+
+// errUsage is returned when the arguments do not name a command or match its usage
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case err == errUsage || err == flag.ErrHelp:
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "bridge:", err)
+		os.Exit(1)
+	}
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	args    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+// commands lists the subcommands by name
+var commands = map[string]command{
+	"register":           {"-email email", "Create an account and save its credentials and a new key", register},
+	"login":              {"-email email", "Save the credentials of an existing account", login},
+	"keygen":             {"[-force]", "Generate a key pair to sign requests with", keygen},
+	"list-buckets":       {"", "List your buckets", listBuckets},
+	"add-bucket":         {"name", "Create a bucket", addBucket},
+	"list-files":         {"bucket", "List the files in a bucket", listFiles},
+	"authorize-upload":   {"bucket", "Stage a frame and print its ID with a token to push shards to it", authorizeUpload},
+	"add-file":           {"[-mimetype type] bucket frame name", "Add a frame whose shards have been pushed to a bucket", addFile},
+	"authorize-download": {"bucket file", "Authorize a pull of a file and print its metadata and token", authorizeDownload},
+	"generate-token":     {"-operation push|pull [-file id] [-frame id] bucket", "Create a push or pull token for a bucket", generateToken},
+}
+
+// env is what commands run with: the parsed global flags, the keyring, and the process's I/O
+type env struct {
+	url         string
+	keyringPath string
+	keyring     *Keyring
+	totp        string
+	json        bool
+
+	// command and args are the name and usage of the command being run
+	command string
+	args    string
+
+	getenv func(string) string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run parses the global flags and runs the named command. Flags take precedence over the
+// environment, which takes precedence over the keyring.
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) error {
+	e := &env{getenv: getenv, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("bridge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(stderr, fs) }
+	fs.StringVar(&e.url, "url", getenv("BRIDGE_URL"), "bridge URL")
+	fs.StringVar(&e.keyringPath, "keyring", or(getenv("BRIDGE_KEYRING"), defaultKeyring(getenv)), "path to the keyring")
+	fs.StringVar(&e.totp, "totp", getenv("BRIDGE_TOTP"), "TOTP or recovery code, for accounts with two-factor enabled")
+	fs.BoolVar(&e.json, "json", false, "print responses as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "bridge: unknown command %q\n", fs.Arg(0))
+		}
+		fs.Usage()
+		return errUsage
+	}
+
+	var err error
+	if e.keyring, err = loadKeyring(e.keyringPath); err != nil {
+		return fmt.Errorf("keyring: %v", err)
+	}
+	e.url = or(e.url, e.keyring.URL, defaultURL)
+	e.command, e.args = fs.Arg(0), cmd.args
+
+	return cmd.run(ctx, e, fs.Args()[1:])
+}
+
+// usage prints the global flags and the commands
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: bridge [flags] <command> [flags] [args]")
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", name, commands[name].args, commands[name].summary)
+	}
+	tw.Flush()
+}
+
+// flags returns a flag set for the command being run that prints its usage on error
+func (e *env) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(e.command, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: bridge %s %s\n", e.command, e.args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parse parses the command's flags and checks that it was given n positional arguments
+func parse(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// client returns a client for the bridge authenticated with the keyring's credentials
+func (e *env) client() (*client.Client, error) {
+	c, err := client.New(e.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.keyring.Email != "" {
+		c.SetBasicAuthHash(e.keyring.Email, e.keyring.PasswordHash)
+		c.TOTPCode = e.totp
+	}
+
+	key, err := e.keyring.key()
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %v", err)
+	}
+	if key != nil {
+		c.SetKey(key)
+	}
+
+	return c, nil
+}
+
+// print writes v as indented JSON if -json was set, or otherwise writes the rows as tab
+// aligned columns
+func (e *env) print(v interface{}, rows ...[]interface{}) error {
+	if e.json {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		for i, col := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, col)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+// or returns the first non-empty value
+func or(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
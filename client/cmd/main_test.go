@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coyle/bridge/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nested", "keyring.json")
+
+	k, err := loadKeyring(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &Keyring{}, k)
+
+	key := client.GenerateKey()
+	k = &Keyring{URL: "http://bridge", Email: "test@storj.io", PasswordHash: client.HashPassword("password"), PrivateKey: key.PrivateHex()}
+	assert.NoError(t, k.save(path))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := loadKeyring(path)
+	assert.NoError(t, err)
+	assert.Equal(t, k, loaded)
+
+	parsed, err := loaded.key()
+	assert.NoError(t, err)
+	assert.Equal(t, key.PublicHex(), parsed.PublicHex())
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var registration client.Registration
+	var bucket client.BucketRequest
+	var file client.FileRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/users" {
+			email, password, _ := r.BasicAuth()
+			signed := client.Verify(r.Header.Get(client.PubKeyHeader), r.Header.Get(client.SignatureHeader), r.Method, r.URL.Path, body)
+			if email != registration.Email || password != registration.Password || !signed {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"unauthorized","message":"invalid credentials"}`))
+				return
+			}
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /users":
+			json.Unmarshal(body, &registration)
+			w.WriteHeader(http.StatusCreated)
+		case "POST /buckets":
+			json.Unmarshal(body, &bucket)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(client.Bucket{ID: "b1", Name: bucket.Name, Pubkeys: bucket.Pubkeys, Status: "Active"})
+		case "GET /buckets":
+			json.NewEncoder(w).Encode([]client.Bucket{{ID: "b1", Name: bucket.Name, Status: "Active"}})
+		case "POST /frames":
+			json.NewEncoder(w).Encode(client.Frame{ID: "f1"})
+		case "POST /buckets/b1/tokens":
+			json.NewEncoder(w).Encode(client.Token{ID: "t1", Operation: client.OperationPush})
+		case "POST /buckets/b1/files":
+			json.Unmarshal(body, &file)
+			json.NewEncoder(w).Encode(client.File{ID: "e1", Name: file.Filename, MimeType: file.MimeType})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not_found","message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	env := map[string]string{
+		"HOME":            dir,
+		"BRIDGE_URL":      srv.URL,
+		"BRIDGE_PASSWORD": "password",
+	}
+	bridge := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := run(context.Background(), args, func(k string) string { return env[k] }, strings.NewReader(""), &stdout, ioutil.Discard)
+		return stdout.String(), err
+	}
+
+	_, err = bridge("register", "-email", "test@storj.io")
+	assert.NoError(t, err)
+	assert.Equal(t, "test@storj.io", registration.Email)
+	assert.Equal(t, client.HashPassword("password"), registration.Password)
+
+	k, err := loadKeyring(defaultKeyring(func(string) string { return dir }))
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL, k.URL)
+	key, err := k.key()
+	assert.NoError(t, err)
+	assert.Equal(t, registration.PublicKey, key.PublicHex())
+
+	// The keyring alone is enough once registered
+	delete(env, "BRIDGE_URL")
+	delete(env, "BRIDGE_PASSWORD")
+
+	out, err := bridge("add-bucket", "photos")
+	assert.NoError(t, err)
+	assert.Equal(t, "b1  photos  Active\n", out)
+	assert.Equal(t, []string{key.PublicHex()}, bucket.Pubkeys)
+
+	out, err = bridge("-json", "list-buckets")
+	assert.NoError(t, err)
+	var buckets []client.Bucket
+	assert.NoError(t, json.Unmarshal([]byte(out), &buckets))
+	assert.Equal(t, "photos", buckets[0].Name)
+
+	out, err = bridge("authorize-upload", "b1")
+	assert.NoError(t, err)
+	assert.Equal(t, "f1  t1\n", out)
+
+	_, err = bridge("add-file", "b1", "f1", "photo.png")
+	assert.NoError(t, err)
+	assert.Equal(t, client.FileRequest{Frame: "f1", Filename: "photo.png", MimeType: "image/png"}, file)
+
+	_, err = bridge("keygen")
+	assert.Error(t, err)
+
+	_, err = bridge("list-files")
+	assert.Equal(t, errUsage, err)
+
+	_, err = bridge("unknown")
+	assert.Equal(t, errUsage, err)
+
+	_, err = bridge("-keyring", filepath.Join(dir, "other.json"), "-url", srv.URL, "list-buckets")
+	assert.Equal(t, http.StatusUnauthorized, client.StatusCode(err))
+}
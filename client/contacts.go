@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+)
+
+// ListContacts returns the storage nodes known to the bridge
+func (c *Client) ListContacts(ctx context.Context) ([]Contact, error) {
+	var contacts []Contact
+	if err := c.do(ctx, "GET", "/contacts", nil, nil, &contacts); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+// GetContact returns the storage node with the ID
+func (c *Client) GetContact(ctx context.Context, nodeID string) (*Contact, error) {
+	contact := &Contact{}
+	if err := c.do(ctx, "GET", "/contacts/"+escape(nodeID), nil, nil, contact); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Error is returned for every response with a 4xx or 5xx status
+type Error struct {
+	StatusCode int `json:"-"`
+	// Code is the machine readable error, such as not_found or rate_limited
+	Code    string `json:"error"`
+	Message string `json:"message"`
+	// RequestID identifies the request in the bridge's logs
+	RequestID string `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("bridge: %d %s: %s (request %s)", e.StatusCode, e.Code, e.Message, e.RequestID)
+	}
+
+	return fmt.Sprintf("bridge: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// StatusCode returns the HTTP status of an *Error, or 0 for any other error
+func StatusCode(err error) int {
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode
+	}
+
+	return 0
+}
+
+// IsNotFound reports whether the error is a 404 from the bridge
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// newError decodes the error body of the response. Bodies that are not the bridge's JSON
+// errors, such as those from a proxy, are described by their status.
+func newError(resp *http.Response) *Error {
+	e := &Error{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(e); err != nil || e.Code == "" {
+		e = &Error{Code: "http_error", Message: http.StatusText(resp.StatusCode)}
+	}
+
+	e.StatusCode = resp.StatusCode
+	e.RequestID = resp.Header.Get("X-Request-ID")
+
+	return e
+}
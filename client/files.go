@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+)
+
+// FileRequest creates a file in a bucket from an uploaded frame
+type FileRequest struct {
+	Frame    string `json:"frame"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mimetype,omitempty"`
+}
+
+// ListFiles returns the files in a bucket
+func (c *Client) ListFiles(ctx context.Context, bucket string) ([]File, error) {
+	var files []File
+	if err := c.do(ctx, "GET", "/buckets/"+escape(bucket)+"/files", nil, nil, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// FileID returns the ID of the file with the name in a bucket
+func (c *Client) FileID(ctx context.Context, bucket, name string) (string, error) {
+	var body struct {
+		ID string `json:"id"`
+	}
+	err := c.do(ctx, "GET", "/buckets/"+escape(bucket)+"/file-ids/"+escape(name), nil, nil, &body)
+
+	return body.ID, err
+}
+
+// FileInfo returns the metadata of a file
+func (c *Client) FileInfo(ctx context.Context, bucket, file string) (*File, error) {
+	f := &File{}
+	if err := c.do(ctx, "GET", "/buckets/"+escape(bucket)+"/files/"+escape(file)+"/info", nil, nil, f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// CreateFile adds a file to a bucket from a frame whose shards have been uploaded
+func (c *Client) CreateFile(ctx context.Context, bucket string, r FileRequest) (*File, error) {
+	f := &File{}
+	if err := c.do(ctx, "POST", "/buckets/"+escape(bucket)+"/files", nil, r, f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// DeleteFile removes a file from a bucket
+func (c *Client) DeleteFile(ctx context.Context, bucket, file string) error {
+	return c.do(ctx, "DELETE", "/buckets/"+escape(bucket)+"/files/"+escape(file), nil, nil, nil)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+)
+
+// CreateFrame starts a frame to stage the shards of an upload
+func (c *Client) CreateFrame(ctx context.Context) (*Frame, error) {
+	f := &Frame{}
+	if err := c.do(ctx, "POST", "/frames", nil, nil, f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ListFrames returns the user's frames
+func (c *Client) ListFrames(ctx context.Context) ([]Frame, error) {
+	var frames []Frame
+	if err := c.do(ctx, "GET", "/frames", nil, nil, &frames); err != nil {
+		return nil, err
+	}
+
+	return frames, nil
+}
+
+// GetFrame returns the frame with the ID
+func (c *Client) GetFrame(ctx context.Context, id string) (*Frame, error) {
+	f := &Frame{}
+	if err := c.do(ctx, "GET", "/frames/"+escape(id), nil, nil, f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// DeleteFrame removes a frame
+func (c *Client) DeleteFrame(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/frames/"+escape(id), nil, nil, nil)
+}
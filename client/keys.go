@@ -0,0 +1,88 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	secp256k1 "github.com/haltingstate/secp256k1-go"
+)
+
+// Headers carrying credentials
+const (
+	PubKeyHeader    = "X-PubKey"
+	SignatureHeader = "X-Signature"
+	TOTPHeader      = "X-TOTP-Code"
+)
+
+// ErrInvalidKey is returned when a private key is not a valid secp256k1 key
+var ErrInvalidKey = errors.New("invalid secp256k1 private key")
+
+// KeyPair is a secp256k1 key pair used to sign requests. The public key is the compressed
+// 33 byte form registered with the bridge.
+type KeyPair struct {
+	Public  []byte
+	Private []byte
+}
+
+// GenerateKey returns a new random key pair
+func GenerateKey() *KeyPair {
+	public, private := secp256k1.GenerateKeyPair()
+	return &KeyPair{Public: public, Private: private}
+}
+
+// ParseKey returns the key pair for a hex encoded private key
+func ParseKey(private string) (*KeyPair, error) {
+	b, err := hex.DecodeString(private)
+	if err != nil || len(b) != 32 || secp256k1.VerifySeckey(b) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	return &KeyPair{Public: secp256k1.PubkeyFromSeckey(b), Private: b}, nil
+}
+
+// PublicHex returns the hex encoded public key, as registered with the bridge
+func (k *KeyPair) PublicHex() string {
+	return hex.EncodeToString(k.Public)
+}
+
+// PrivateHex returns the hex encoded private key
+func (k *KeyPair) PrivateHex() string {
+	return hex.EncodeToString(k.Private)
+}
+
+// Sign returns the hex encoded signature of a request. The signed message is the method, path,
+// and payload separated by newlines, where the payload is the JSON body or, for requests
+// without one, the encoded query string.
+//
+// The signature is the 65 byte compact secp256k1 form, not the DER encoding the legacy bridge
+// accepted, and no bridge endpoint verifies it yet. Verify is the reference for a server side
+// check.
+func (k *KeyPair) Sign(method, path string, payload []byte) string {
+	return hex.EncodeToString(secp256k1.Sign(message(method, path, payload), k.Private))
+}
+
+// Verify reports whether signature is a valid hex encoded signature of the request by the hex
+// encoded public key
+func Verify(public, signature, method, path string, payload []byte) bool {
+	pub, err := hex.DecodeString(public)
+	if err != nil || len(pub) != 33 {
+		return false
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != 65 {
+		return false
+	}
+
+	return secp256k1.VerifySignature(message(method, path, payload), sig, pub) == 1
+}
+
+// message returns the SHA-256 of the request description that is signed
+func message(method, path string, payload []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(method + "\n" + path + "\n"))
+	h.Write(payload)
+
+	return h.Sum(nil)
+}
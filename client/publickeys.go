@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+)
+
+// ListKeys returns the public keys registered to the user
+func (c *Client) ListKeys(ctx context.Context) ([]PublicKey, error) {
+	var keys []PublicKey
+	if err := c.do(ctx, "GET", "/keys", nil, nil, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// AddKey registers a hex encoded public key to the user
+func (c *Client) AddKey(ctx context.Context, key string) (*PublicKey, error) {
+	k := &PublicKey{}
+	if err := c.do(ctx, "POST", "/keys", nil, map[string]string{"key": key}, k); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// RemoveKey unregisters a public key
+func (c *Client) RemoveKey(ctx context.Context, key string) error {
+	return c.do(ctx, "DELETE", "/keys/"+escape(key), nil, nil, nil)
+}
@@ -0,0 +1,16 @@
+package client
+
+import (
+	"context"
+)
+
+// CreateExchangeReport reports the outcome of a shard transfer. The authenticated user is
+// recorded as the client.
+func (c *Client) CreateExchangeReport(ctx context.Context, r ExchangeReport) (*ExchangeReport, error) {
+	report := &ExchangeReport{}
+	if err := c.do(ctx, "POST", "/reports/exchanges", nil, r, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"github.com/coyle/bridge/server/billing"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// The bridge's own types are used for responses so the client always decodes what it encodes
+type (
+	User              = mongodb.User
+	Usage             = mongodb.Usage
+	Preferences       = mongodb.Preferences
+	PreferencesUpdate = mongodb.PreferencesUpdate
+	UserExport        = mongodb.UserExport
+	PublicKey         = mongodb.PublicKey
+	Bucket            = mongodb.Bucket
+	File              = mongodb.BucketEntry
+	Frame             = mongodb.Frame
+	Token             = mongodb.Token
+	Contact           = mongodb.Contact
+	ExchangeReport    = mongodb.ExchangeReport
+	Invoice           = billing.Invoice
+)
+
+// Token operations
+const (
+	OperationPush = mongodb.OperationPush
+	OperationPull = mongodb.OperationPull
+)
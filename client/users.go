@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+)
+
+// Registration is the body of a new user
+type Registration struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// PublicKey is the hex encoded public key the user signs requests with
+	PublicKey       string `json:"pubkey"`
+	ReferralPartner string `json:"referralPartner,omitempty"`
+}
+
+// UserUpdate changes the preferences present and requests a change to a new email address
+type UserUpdate struct {
+	Email       string             `json:"email,omitempty"`
+	Preferences *PreferencesUpdate `json:"preferences,omitempty"`
+}
+
+// TOTPEnrollment is the secret to add to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// userRequest is the subset of the bridge's user request body the client sends
+type userRequest struct {
+	Email     string            `json:"email,omitempty"`
+	Password  string            `json:"password,omitempty"`
+	Code      string            `json:"code,omitempty"`
+	Processor string            `json:"processor,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// Register creates a user and emails them an activation link. The password is sent hashed.
+func (c *Client) Register(ctx context.Context, r Registration) error {
+	r.Password = HashPassword(r.Password)
+	return c.do(ctx, "POST", "/users", nil, r, nil)
+}
+
+// ResendActivation emails a new activation link if the address belongs to an inactive user.
+// The bridge answers the same for any address.
+func (c *Client) ResendActivation(ctx context.Context, email string) error {
+	return c.do(ctx, "POST", "/activations", nil, userRequest{Email: email}, nil)
+}
+
+// ConfirmActivation activates the user the emailed token was issued to
+func (c *Client) ConfirmActivation(ctx context.Context, token string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "GET", "/activations/"+escape(token), nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Deactivate emails the user a link to deactivate their account
+func (c *Client) Deactivate(ctx context.Context, id string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "DELETE", "/users/"+escape(id), nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ConfirmDeactivation deactivates the user the emailed token was issued to
+func (c *Client) ConfirmDeactivation(ctx context.Context, token string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "POST", "/deactivations/"+escape(token), nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// RequestPasswordReset emails a password reset link to the address if it belongs to a user.
+// The bridge does not reveal whether it does.
+func (c *Client) RequestPasswordReset(ctx context.Context, email string) error {
+	return c.do(ctx, "POST", "/resets", nil, userRequest{Email: email}, nil)
+}
+
+// ConfirmPasswordReset sets a new password for the user the emailed token was issued to.
+// Users with two-factor enabled must also set TOTPCode.
+func (c *Client) ConfirmPasswordReset(ctx context.Context, token, password string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "POST", "/resets/"+escape(token), nil, userRequest{Password: HashPassword(password)}, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// UpdateUser changes the user's preferences or requests a change of email address
+func (c *Client) UpdateUser(ctx context.Context, id string, update UserUpdate) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "PATCH", "/users/"+escape(id), nil, update, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ConfirmEmailChange replaces the user's email with the address the token was sent to
+func (c *Client) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "GET", "/emails/"+escape(token), nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Usage returns the user's transfer counters
+func (c *Client) Usage(ctx context.Context, id string) (*Usage, error) {
+	u := &Usage{}
+	if err := c.do(ctx, "GET", "/users/"+escape(id)+"/usage", nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Invoices returns the user's invoices
+func (c *Client) Invoices(ctx context.Context, id string) ([]Invoice, error) {
+	var invoices []Invoice
+	if err := c.do(ctx, "GET", "/users/"+escape(id)+"/invoices", nil, nil, &invoices); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+// Export returns everything the bridge stores about the user
+func (c *Client) Export(ctx context.Context, id string) (*UserExport, error) {
+	e := &UserExport{}
+	if err := c.do(ctx, "GET", "/users/"+escape(id)+"/export", nil, nil, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ExportZip writes the user's export to w as a zip archive with one JSON file per collection
+func (c *Client) ExportZip(ctx context.Context, id string, w io.Writer) error {
+	return c.do(ctx, "GET", "/users/"+escape(id)+"/export", url.Values{"format": {"zip"}}, nil, w)
+}
+
+// EnrollTOTP generates a TOTP secret for the user. It takes effect once confirmed with EnableTOTP.
+func (c *Client) EnrollTOTP(ctx context.Context, id string) (*TOTPEnrollment, error) {
+	e := &TOTPEnrollment{}
+	if err := c.do(ctx, "POST", "/users/"+escape(id)+"/totp", nil, nil, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// EnableTOTP turns on two-factor with a code from the enrolled secret and returns the recovery codes
+func (c *Client) EnableTOTP(ctx context.Context, id, code string) ([]string, error) {
+	var body struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	err := c.do(ctx, "POST", "/users/"+escape(id)+"/totp/verify", nil, userRequest{Code: code}, &body)
+
+	return body.RecoveryCodes, err
+}
+
+// DisableTOTP turns off two-factor for the user
+func (c *Client) DisableTOTP(ctx context.Context, id string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "DELETE", "/users/"+escape(id)+"/totp", nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Preferences returns the user's preferences
+func (c *Client) Preferences(ctx context.Context, id string) (*Preferences, error) {
+	p := &Preferences{}
+	if err := c.do(ctx, "GET", "/users/"+escape(id)+"/preferences", nil, nil, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// UpdatePreferences changes the preferences present in the update
+func (c *Client) UpdatePreferences(ctx context.Context, id string, update PreferencesUpdate) (*Preferences, error) {
+	p := &Preferences{}
+	if err := c.do(ctx, "PATCH", "/users/"+escape(id)+"/preferences", nil, update, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// AddPaymentProcessor registers the user with a payment processor
+func (c *Client) AddPaymentProcessor(ctx context.Context, id, processor string, data map[string]string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "POST", "/users/"+escape(id)+"/payment-processors", nil, userRequest{Processor: processor, Data: data}, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// RemovePaymentProcessor unregisters the user from a payment processor
+func (c *Client) RemovePaymentProcessor(ctx context.Context, id, processor string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "DELETE", "/users/"+escape(id)+"/payment-processors/"+escape(processor), nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// SetDefaultPaymentProcessor makes one of the user's payment processors their default
+func (c *Client) SetDefaultPaymentProcessor(ctx context.Context, id, processor string) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, "PUT", "/users/"+escape(id)+"/payment-processors/"+escape(processor)+"/default", nil, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,119 @@
+package billing
+
+import (
+	"math"
+	"time"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+const gb = 1000 * 1000 * 1000
+
+// Prices defines the cost of a tier in US dollars
+type Prices struct {
+	StorageGBMonth float64 `json:"storageGBMonth"`
+	TransferGB     float64 `json:"transferGB"`
+}
+
+// PriceTable contains the prices charged to free and paid users
+type PriceTable struct {
+	Free Prices `json:"free"`
+	Paid Prices `json:"paid"`
+}
+
+// DefaultPriceTable returns the prices used when none are configured
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		Paid: Prices{
+			StorageGBMonth: 0.015,
+			TransferGB:     0.05,
+		},
+	}
+}
+
+// For returns the prices that apply to the provided user
+func (t PriceTable) For(u *mongodb.User) Prices {
+	if u.IsFreeTier {
+		return t.Free
+	}
+
+	return t.Paid
+}
+
+// Invoice groups the debits of a single billing period
+type Invoice struct {
+	PeriodStart time.Time       `json:"periodStart"`
+	PeriodEnd   time.Time       `json:"periodEnd"`
+	Debits      []mongodb.Debit `json:"debits"`
+	Total       int64           `json:"total"`
+}
+
+// Period returns the calendar month in UTC that contains t
+func Period(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return start, start.AddDate(0, 1, 0)
+}
+
+// StorageGBMonths returns how many gigabyte-months the shards were stored for within [start, end)
+func StorageGBMonths(shards []mongodb.Shard, start, end time.Time) float64 {
+	period := end.Sub(start).Seconds()
+	if period <= 0 {
+		return 0
+	}
+
+	var byteSeconds float64
+	for _, s := range shards {
+		begin, finish := s.StoredSpan()
+		if begin.Before(start) {
+			begin = start
+		}
+		if finish.After(end) {
+			finish = end
+		}
+		if !finish.After(begin) {
+			continue
+		}
+
+		byteSeconds += float64(s.Size) * finish.Sub(begin).Seconds()
+	}
+
+	return byteSeconds / period / gb
+}
+
+// TransferGB returns the gigabytes downloaded in the reports given the size of each shard
+func TransferGB(reports []mongodb.ExchangeReport, sizes map[string]int64) float64 {
+	var bytes int64
+	for _, r := range reports {
+		bytes += sizes[r.DataHash]
+	}
+
+	return float64(bytes) / gb
+}
+
+// Invoices groups debits into one invoice per billing period
+func Invoices(debits []mongodb.Debit) []Invoice {
+	invoices := []Invoice{}
+	index := map[time.Time]int{}
+
+	for _, d := range debits {
+		key := d.PeriodStart.UTC()
+		i, ok := index[key]
+		if !ok {
+			i = len(invoices)
+			index[key] = i
+			invoices = append(invoices, Invoice{PeriodStart: key, PeriodEnd: d.PeriodEnd.UTC(), Debits: []mongodb.Debit{}})
+		}
+
+		invoices[i].Debits = append(invoices[i].Debits, d)
+		invoices[i].Total += d.Amount
+	}
+
+	return invoices
+}
+
+// cents converts a dollar amount to whole cents
+func cents(usd float64) int64 {
+	return int64(math.Round(usd * 100))
+}
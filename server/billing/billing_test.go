@@ -0,0 +1,76 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+func TestPeriod(t *testing.T) {
+	start, end := Period(time.Date(2018, time.February, 14, 9, 30, 0, 0, time.UTC))
+
+	assert.Equal(t, time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC), end)
+}
+
+func TestStorageGBMonths(t *testing.T) {
+	start, end := Period(time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC))
+	mid := start.Add(end.Sub(start) / 2)
+
+	shards := []mongodb.Shard{
+		// stored for the whole month across two mirrors
+		{Size: 2 * gb, Contracts: []mongodb.Contract{
+			{StoreBegin: start.AddDate(0, -1, 0), StoreEnd: end.AddDate(0, 1, 0)},
+			{StoreBegin: start, StoreEnd: mid},
+		}},
+		// stored for the second half of the month
+		{Size: gb, Contracts: []mongodb.Contract{{StoreBegin: mid, StoreEnd: end.AddDate(1, 0, 0)}}},
+		// expired before the period started
+		{Size: gb, Contracts: []mongodb.Contract{{StoreBegin: start.AddDate(0, -2, 0), StoreEnd: start}}},
+	}
+
+	assert.InDelta(t, 2.5, StorageGBMonths(shards, start, end), 0.0001)
+}
+
+func TestTransferGB(t *testing.T) {
+	reports := []mongodb.ExchangeReport{{DataHash: "a"}, {DataHash: "a"}, {DataHash: "b"}, {DataHash: "unknown"}}
+	sizes := map[string]int64{"a": gb, "b": gb / 2}
+
+	assert.InDelta(t, 2.5, TransferGB(reports, sizes), 0.0001)
+}
+
+func TestDebits(t *testing.T) {
+	start, end := Period(time.Now())
+	prices := DefaultPriceTable().Paid
+
+	debits := Debits("user@storj.io", prices, 100, 10, start, end)
+	assert.Len(t, debits, 2)
+	assert.Equal(t, mongodb.DebitStorage, debits[0].Type)
+	assert.Equal(t, int64(150), debits[0].Amount)
+	assert.Equal(t, mongodb.DebitBandwidth, debits[1].Type)
+	assert.Equal(t, int64(50), debits[1].Amount)
+
+	assert.Empty(t, Debits("user@storj.io", DefaultPriceTable().Free, 100, 10, start, end))
+}
+
+func TestInvoices(t *testing.T) {
+	jan, feb := Period(time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC))
+	_, mar := Period(feb)
+
+	debits := []mongodb.Debit{
+		{Type: mongodb.DebitStorage, Amount: 100, PeriodStart: jan, PeriodEnd: feb},
+		{Type: mongodb.DebitBandwidth, Amount: 50, PeriodStart: jan, PeriodEnd: feb},
+		{Type: mongodb.DebitStorage, Amount: 75, PeriodStart: feb, PeriodEnd: mar},
+	}
+
+	invoices := Invoices(debits)
+	assert.Len(t, invoices, 2)
+	assert.Equal(t, jan, invoices[0].PeriodStart)
+	assert.Equal(t, int64(150), invoices[0].Total)
+	assert.Len(t, invoices[0].Debits, 2)
+	assert.Equal(t, feb, invoices[1].PeriodStart)
+	assert.Equal(t, int64(75), invoices[1].Total)
+}
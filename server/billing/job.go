@@ -0,0 +1,163 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// Job converts stored shards and downloads into debits
+type Job struct {
+	db     *mongodb.Client
+	logger log.Logger
+	prices PriceTable
+}
+
+// NewJob returns a new instance of a configured billing Job
+func NewJob(client *mongodb.Client, logger log.Logger, prices PriceTable) *Job {
+	return &Job{
+		db:     client,
+		logger: logger,
+		prices: prices,
+	}
+}
+
+// runLease is how long a billing run may go without completing before another instance takes
+// the period over
+const runLease = 6 * time.Hour
+
+// Run writes storage and bandwidth debits for every user for the period [start, end). Debits
+// are keyed by user, type, and period and never replaced, so a period can be re-run without
+// double billing or changing what was issued.
+func (j *Job) Run(start, end time.Time) error {
+	return j.db.ForEachUser(func(u *mongodb.User) error {
+		debits, err := j.debits(u, start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range debits {
+			if _, err := j.db.SaveDebit(d); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Schedule bills the previous month each time a new month begins. A month is billed once
+// across restarts and instances; the first instance to claim it runs it. The others keep
+// checking until the run completes, so they can take over a run that stalls. It returns when
+// ctx is done.
+func (j *Job) Schedule(ctx context.Context, interval time.Duration) {
+	var billed time.Time
+
+	for {
+		current, _ := Period(time.Now())
+		if !current.Equal(billed) {
+			completed, err := j.runOnce(current.AddDate(0, -1, 0))
+			if err != nil {
+				level.Error(j.logger).Log("msg", "billing run failed", "err", err, "period", current.AddDate(0, -1, 0))
+			} else if completed {
+				billed = current
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runOnce bills the month containing t unless it has been billed or another run holds it. It
+// reports whether the month has been billed.
+func (j *Job) runOnce(t time.Time) (bool, error) {
+	start, end := Period(t)
+
+	claimed, err := j.db.ClaimBillingRun(start, runLease)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		level.Debug(j.logger).Log("msg", "billing run already claimed", "period", start)
+		return j.db.BillingRunCompleted(start)
+	}
+
+	if err := j.Run(start, end); err != nil {
+		return false, err
+	}
+
+	if err := j.db.CompleteBillingRun(start); err != nil {
+		return false, err
+	}
+
+	level.Info(j.logger).Log("msg", "billing run complete", "period", start)
+	return true, nil
+}
+
+func (j *Job) debits(u *mongodb.User, start, end time.Time) ([]mongodb.Debit, error) {
+	prices := j.prices.For(u)
+
+	shards, err := j.db.ListUserShards(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := j.db.ListClientDownloads(u.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(reports))
+	for _, r := range reports {
+		hashes = append(hashes, r.DataHash)
+	}
+
+	downloaded, err := j.db.GetShards(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := map[string]int64{}
+	for _, s := range downloaded {
+		sizes[s.ID] = s.Size
+	}
+
+	return Debits(u.ID, prices, StorageGBMonths(shards, start, end), TransferGB(reports, sizes), start, end), nil
+}
+
+// Debits prices the usage of a user for the period. Usage that rounds to no charge is omitted.
+func Debits(user string, prices Prices, storageGBMonths, transferGB float64, start, end time.Time) []mongodb.Debit {
+	debits := []mongodb.Debit{}
+
+	if amount := cents(storageGBMonths * prices.StorageGBMonth); amount > 0 {
+		debits = append(debits, mongodb.Debit{
+			User:        user,
+			Type:        mongodb.DebitStorage,
+			Quantity:    storageGBMonths,
+			Amount:      amount,
+			PeriodStart: start,
+			PeriodEnd:   end,
+		})
+	}
+
+	if amount := cents(transferGB * prices.TransferGB); amount > 0 {
+		debits = append(debits, mongodb.Debit{
+			User:        user,
+			Type:        mongodb.DebitBandwidth,
+			Quantity:    transferGB,
+			Amount:      amount,
+			PeriodStart: start,
+			PeriodEnd:   end,
+		})
+	}
+
+	return debits
+}
@@ -1,19 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	// "github.com/spf13/viper"
+	"github.com/coyle/bridge/server/billing"
+	"github.com/coyle/bridge/server/config"
+	"github.com/coyle/bridge/server/cors"
+	"github.com/coyle/bridge/server/health"
+	"github.com/coyle/bridge/server/https"
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/mailer"
+	"github.com/coyle/bridge/server/metrics"
+	"github.com/coyle/bridge/server/payments"
+	"github.com/coyle/bridge/server/purge"
+	"github.com/coyle/bridge/server/ratelimit"
 	"github.com/coyle/bridge/server/routes"
 	"github.com/coyle/bridge/server/routes/buckets"
 	"github.com/coyle/bridge/server/routes/contacts"
 	"github.com/coyle/bridge/server/routes/files"
 	"github.com/coyle/bridge/server/routes/frames"
 	"github.com/coyle/bridge/server/routes/keys"
+	"github.com/coyle/bridge/server/routes/partners"
 	"github.com/coyle/bridge/server/routes/reports"
 	"github.com/coyle/bridge/server/routes/users"
 	"github.com/coyle/bridge/storage/mongodb"
@@ -24,83 +40,368 @@ import (
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))
-	logger = level.NewFilter(logger, level.AllowAll())
+	logger = level.NewFilter(logger, logLevels[cfg.LogLevel])
 
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	os.Exit(run(cfg, logger))
+}
+
+// run serves the bridge until it is signalled to stop and returns the process's exit code. The
+// background jobs are stopped and waited for before the database connection is closed.
+func run(cfg config.Config, logger log.Logger) int {
+	storageClient, err := mongodb.Connect(cfg.Mongo.URL, cfg.Mongo.Database, cfg.Mongo.ConnectTimeout.Duration, func(err error, wait time.Duration) {
+		level.Warn(logger).Log("msg", "failed to connect to mongo, retrying", "err", err, "wait", wait)
+	})
 	if err != nil {
-		level.Error(logger).Log("Error connecting", err)
-		// TODO(coyle): handle appropriately
-		// return
+		level.Error(logger).Log("msg", "failed to connect to mongo", "err", err)
+		return 1
+	}
+	defer storageClient.Close()
+
+	var mail mailer.Mailer = mailer.NewLog(logger)
+	switch {
+	case cfg.Mailer.SMTP != "":
+		if mail, err = mailer.NewSMTP(cfg.Mailer.SMTP, cfg.Mailer.From); err != nil {
+			level.Error(logger).Log("msg", "failed to configure mailer", "err", err)
+			return 1
+		}
+	case cfg.Mailer.File != "":
+		mail = mailer.NewFile(cfg.Mailer.File)
+	}
+
+	var limits ratelimit.Store = ratelimit.NewMemory()
+	if cfg.RateLimit.Store == "mongo" {
+		if limits, err = ratelimit.NewMongo(storageClient); err != nil {
+			level.Error(logger).Log("msg", "failed to configure rate limits", "err", err)
+			return 1
+		}
+	}
+	limiter := ratelimit.NewLimiter(limits)
+	limiter.TrustForwarded = cfg.RateLimit.TrustForwarded
+	updatePolicy.Key = ratelimit.ResolveKey(ratelimit.ParamKey("id"), func(key string) (string, error) {
+		u, err := storageClient.LookupUser(key)
+		if err != nil {
+			return "", err
+		}
+		return u.ID, nil
+	})
+
+	checks := []health.Check{health.Mongo(storageClient, readyTimeout)}
+	if pinger, ok := mail.(health.Pinger); ok {
+		checks = append(checks, health.Mailer(pinger))
+	}
+	if cfg.Engine.URL != "" {
+		checks = append(checks, health.Engine(&http.Client{Timeout: readyTimeout}, cfg.Engine.URL))
 	}
 
+	instruments := metrics.New()
+	storageClient.SetObserver(instruments.ObserveDB)
+
 	handler := routes.Handler{
-		Logger: logger,
-		User:   users.NewServer(storageClient, logger),
+		Logger:  logger,
+		User:    users.NewServer(storageClient, mail, mailer.NewTemplates(cfg.URL), paymentProcessors(cfg.Payments)),
+		Bucket:  buckets.NewServer(storageClient, cfg.Quotas),
+		Frame:   frames.NewServer(storageClient, cfg.Quotas),
+		Partner: partners.NewServer(storageClient),
+		Report:  reports.NewServer(storageClient),
+		Limiter: limiter,
+		Health:  health.NewServer(readyTimeout, checks...),
+		Metrics: instruments,
+	}
+
+	// serving before every migration has completed would mix old and new documents, so a
+	// failed migration stops the server; each is safe to resume on the next start
+	migrations := []struct {
+		name    string
+		migrate func() (int, error)
+	}{
+		{"user IDs", storageClient.MigrateUserIDs},
+		{"legacy passwords", storageClient.MigrateLegacyPasswords},
+		{"legacy tokens", storageClient.MigrateLegacyTokens},
+		{"partner revenue shares", storageClient.MigratePartnerRevShare},
+	}
+	for _, m := range migrations {
+		n, err := m.migrate()
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to migrate "+m.name, "err", err, "migrated", n)
+			return 1
+		}
+		if n > 0 {
+			level.Info(logger).Log("msg", "migrated "+m.name, "migrated", n)
+		}
 	}
 
-	http.ListenAndServe(":8080", start(&handler))
+	ctx, cancel := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	defer func() {
+		cancel()
+		jobs.Wait()
+	}()
 
-	signalChan := make(chan os.Signal)
+	background := func(job func()) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job()
+		}()
+	}
+	background(func() { sweepTokens(ctx, storageClient, logger, time.Hour) })
+	background(func() { billing.NewJob(storageClient, logger, billing.DefaultPriceTable()).Schedule(ctx, time.Hour) })
+	background(func() { purge.NewJob(storageClient, logger, cfg.Purge.GracePeriod.Duration).Schedule(ctx, time.Hour) })
+	background(func() { instruments.Track(ctx, storageClient, logger, time.Minute) })
+
+	server := &http.Server{
+		Addr:    cfg.Listen,
+		Handler: cors.Handler(corsPolicy(cfg.CORS), start(&handler)),
+	}
+	servers := []*http.Server{server}
+
+	errc := make(chan error, 3)
+	if cfg.TLS.Enabled() {
+		certs, err := https.NewReloader(cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to load certificate", "err", err)
+			return 1
+		}
+		server.TLSConfig = certs.Config()
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		background(func() { certs.Watch(ctx, certPollInterval, hup, logger) })
+
+		go func() { errc <- server.ListenAndServeTLS("", "") }()
+
+		if cfg.TLS.RedirectListen != "" {
+			_, port, _ := net.SplitHostPort(cfg.Listen)
+			redirect := &http.Server{
+				Addr:    cfg.TLS.RedirectListen,
+				Handler: https.Redirect(port),
+			}
+			servers = append(servers, redirect)
+
+			go func() { errc <- redirect.ListenAndServe() }()
+			level.Info(logger).Log("msg", "redirecting to https", "addr", cfg.TLS.RedirectListen)
+		}
+	} else {
+		go func() { errc <- server.ListenAndServe() }()
+	}
+	level.Info(logger).Log("msg", "server listening", "addr", cfg.Listen, "tls", cfg.TLS.Enabled())
+
+	// metrics reveal traffic and stored totals, so they stay off the public API listener
+	if cfg.MetricsListen != "" {
+		metricsRouter := httprouter.New()
+		metricsRouter.GET("/metrics", instruments.Handler)
+		metricsServer := &http.Server{
+			Addr:    cfg.MetricsListen,
+			Handler: metricsRouter,
+		}
+		servers = append(servers, metricsServer)
+
+		go func() { errc <- metricsServer.ListenAndServe() }()
+		level.Info(logger).Log("msg", "serving metrics", "addr", cfg.MetricsListen)
+	}
+
+	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-signalChan
 
-	level.Info(logger).Log("Server Stopping", "bridger-server", "sig", sig)
+	select {
+	case err := <-errc:
+		level.Error(logger).Log("msg", "server failed", "err", err)
+		return 1
+	case sig := <-signalChan:
+		level.Info(logger).Log("msg", "server stopping", "sig", sig)
+	}
+
+	cancel()
+
+	shutdownCtx, done := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer done()
+
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			level.Error(logger).Log("msg", "failed to drain requests", "err", err, "addr", s.Addr)
+			return 1
+		}
+	}
+
+	level.Info(logger).Log("msg", "server stopped")
+	return 0
+}
+
+// certPollInterval is how often the TLS certificate files are checked for changes
+const certPollInterval = time.Minute
+
+// readyTimeout bounds each dependency check made by the readiness endpoint
+const readyTimeout = 2 * time.Second
+
+// logLevels maps the configured log level to the filter that allows it and everything more severe
+var logLevels = map[string]level.Option{
+	"debug": level.AllowDebug(),
+	"info":  level.AllowInfo(),
+	"warn":  level.AllowWarn(),
+	"error": level.AllowError(),
+}
+
+// paymentProcessors returns a registry of the configured payment processors
+func paymentProcessors(c config.Payments) *payments.Registry {
+	var adapters []payments.Adapter
+	if c.StripeKey != "" {
+		adapters = append(adapters, payments.NewStripe(c.StripeKey))
+	}
+	if c.Fake {
+		adapters = append(adapters, payments.NewFake())
+	}
+
+	return payments.NewRegistry(adapters...)
 }
 
+// corsPolicy converts the CORS settings for the cors package
+func corsPolicy(c config.CORS) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins: c.AllowedOrigins,
+		AllowedMethods: c.AllowedMethods,
+		AllowedHeaders: c.AllowedHeaders,
+		ExposedHeaders: c.ExposedHeaders,
+		MaxAge:         c.MaxAge.Duration,
+	}
+}
+
+// Rate limits for endpoints that send email or accept secrets. Account limits stop a single
+// address being flooded with mail, IP limits stop token and password guessing.
+var (
+	signupPolicy = ratelimit.Policy{
+		Name:    "signup",
+		IP:      ratelimit.Rate{Limit: 10, Per: time.Hour},
+		Account: ratelimit.Rate{Limit: 3, Per: time.Hour},
+		Key:     ratelimit.BodyKey("email"),
+	}
+	activationPolicy = ratelimit.Policy{
+		Name:    "activation",
+		IP:      ratelimit.Rate{Limit: 10, Per: time.Hour},
+		Account: ratelimit.Rate{Limit: 3, Per: time.Hour},
+		Key:     ratelimit.BodyKey("email"),
+	}
+	resetPolicy = ratelimit.Policy{
+		Name:    "reset",
+		IP:      ratelimit.Rate{Limit: 10, Per: time.Hour},
+		Account: ratelimit.Rate{Limit: 3, Per: time.Hour},
+		Key:     ratelimit.BodyKey("email"),
+	}
+	resetConfirmPolicy = ratelimit.Policy{
+		Name: "reset-confirm",
+		IP:   ratelimit.Rate{Limit: 10, Per: time.Minute},
+	}
+	// updatePolicy is keyed in run, where the account is resolved from either of its IDs
+	updatePolicy = ratelimit.Policy{
+		Name:    "update",
+		IP:      ratelimit.Rate{Limit: 30, Per: time.Minute},
+		Account: ratelimit.Rate{Limit: 10, Per: time.Minute},
+	}
+)
+
 func start(handler *routes.Handler) *httprouter.Router {
 	router := httprouter.New()
+	register(routes.NewRouter(router, handler.Metrics.Instrument, logging.Middleware(handler.Logger)), handler)
+
+	return router
+}
+
+// register adds every route of the API. Each route must also be documented in routes.Spec.
+func register(r *routes.Router, handler *routes.Handler) {
 	// Bucket specific routes
-	router.GET("/buckets", buckets.Get)
-	router.GET("/buckets/:id", buckets.GetByID)
-	router.GET("/bucket-ids/:name", buckets.GetByID)
-	router.POST("/buckets", buckets.Create)
-	router.DELETE("/buckets/:id", buckets.DestroyByID)
-	router.PATCH("/buckets/:id", buckets.UpdateByID)
-	router.POST("/buckets/:id/tokens", buckets.CreateToken)
+	r.GET("/buckets", handler.Bucket.Get)
+	r.GET("/buckets/:id", handler.Bucket.GetByID)
+	r.GET("/bucket-ids/:name", handler.Bucket.GetByID)
+	r.POST("/buckets", handler.Bucket.Create)
+	r.DELETE("/buckets/:id", handler.Bucket.DestroyByID)
+	r.PATCH("/buckets/:id", handler.Bucket.UpdateByID)
+	r.POST("/buckets/:id/tokens", handler.Bucket.CreateToken)
 	// File specific routes
-	router.GET("/buckets/:id/files", files.List)
-	router.GET("/buckets/:id/file-ids/:name", files.GetID)
-	router.GET("/buckets/:id/files/:file", files.Get)
-	router.DELETE("/buckets/:id/files/:file", files.Delete)
-	router.GET("/buckets/:id/files/:file/info", files.GetInfo)
-	router.POST("/buckets/:id/files", files.CreateEntryFromFrame)
-	router.GET("/buckets/:id/files/:file/mirrors", files.ListMirrorsForFile)
+	r.GET("/buckets/:id/files", files.List)
+	r.GET("/buckets/:id/file-ids/:name", files.GetID)
+	r.GET("/buckets/:id/files/:file", files.Get)
+	r.DELETE("/buckets/:id/files/:file", files.Delete)
+	r.GET("/buckets/:id/files/:file/info", files.GetInfo)
+	r.POST("/buckets/:id/files", files.CreateEntryFromFrame)
+	r.GET("/buckets/:id/files/:file/mirrors", files.ListMirrorsForFile)
 	// Contact specific routes
-	router.GET("/contacts", contacts.GetList)
-	router.GET("/contacts/:nodeID", contacts.GetByNodeID)
-	router.PATCH("/contacts/:nodeID", contacts.PatchByNodeID)
-	router.POST("/contacts", contacts.Create)
-	router.POST("/contacts/challenges", contacts.CreateChallenge)
+	r.GET("/contacts", contacts.GetList)
+	r.GET("/contacts/:nodeID", contacts.GetByNodeID)
+	r.PATCH("/contacts/:nodeID", contacts.PatchByNodeID)
+	r.POST("/contacts", contacts.Create)
+	r.POST("/contacts/challenges", contacts.CreateChallenge)
 	// Frames specific routes
-	router.POST("/frames", frames.Create)
-	router.PUT("/frames:frame", frames.AddShard)
-	router.DELETE("/frames/:frame", frames.RemoveByID)
-	router.GET("/frames", frames.Get)
-	router.GET("/frames/:frame", frames.GetByID)
+	r.POST("/frames", handler.Frame.Create)
+	r.PUT("/frames/:frame", handler.Frame.AddShard)
+	r.DELETE("/frames/:frame", handler.Frame.RemoveByID)
+	r.GET("/frames", handler.Frame.Get)
+	r.GET("/frames/:frame", handler.Frame.GetByID)
 	// Public Key specific routes
-	router.GET("/keys", keys.Get)
-	router.POST("/keys", keys.Add)
-	router.DELETE("/keys/:pubkey", keys.Remove)
+	r.GET("/keys", keys.Get)
+	r.POST("/keys", keys.Add)
+	r.DELETE("/keys/:pubkey", keys.Remove)
 	// Report specific routes
-	router.POST("/reports/exchanges", reports.Create)
+	r.POST("/reports/exchanges", handler.Report.Create)
+	// Partner specific routes
+	r.GET("/partners", handler.Partner.List)
+	r.POST("/partners", handler.Partner.Create)
+	r.PATCH("/partners/:id", handler.Partner.Update)
+	r.GET("/partners/:id/report", handler.Partner.Report)
 	// User specific routes
-	router.POST("/users", handler.User.Create)
-	router.POST("/activations", handler.User.Reactivate)
-	router.GET("/activations/:token", handler.User.ConfirmActivation)
-	router.DELETE("/users/:id", handler.User.Remove)
-	router.GET("/deactivations/:token", handler.User.ConfirmDeactivation)
-	router.PATCH("/users/:id", handler.User.CreatePasswordResetToken)
-	router.POST("/resets/:token", handler.User.ConfirmPasswordReset)
+	r.POST("/users", handler.Limiter.Limit(signupPolicy, handler.User.Create))
+	r.POST("/activations", handler.Limiter.Limit(activationPolicy, handler.User.Reactivate))
+	r.GET("/activations/:token", handler.User.ConfirmActivation)
+	r.DELETE("/users/:id", handler.User.Remove)
+	r.GET("/deactivations/:token", handler.User.DeactivationForm)
+	r.POST("/deactivations/:token", handler.User.ConfirmDeactivation)
+	r.PATCH("/users/:id", handler.Limiter.Limit(updatePolicy, handler.User.Update))
+	r.GET("/emails/:token", handler.User.ConfirmEmailChange)
+	r.POST("/resets", handler.Limiter.Limit(resetPolicy, handler.User.CreatePasswordResetToken))
+	r.GET("/resets/:token", handler.User.ResetForm)
+	r.POST("/resets/:token", handler.Limiter.Limit(resetConfirmPolicy, handler.User.ConfirmPasswordReset))
+	r.GET("/users/:id/usage", handler.User.Usage)
+	r.GET("/users/:id/invoices", handler.User.Invoices)
+	r.GET("/users/:id/export", handler.User.Export)
+	r.POST("/users/:id/totp", handler.User.EnrollTOTP)
+	r.POST("/users/:id/totp/verify", handler.User.EnableTOTP)
+	r.DELETE("/users/:id/totp", handler.User.DisableTOTP)
+	r.GET("/users/:id/preferences", handler.User.GetPreferences)
+	r.PATCH("/users/:id/preferences", handler.User.UpdatePreferences)
+	r.POST("/users/:id/payment-processors", handler.User.AddPaymentProcessor)
+	r.DELETE("/users/:id/payment-processors/:processor", handler.User.RemovePaymentProcessor)
+	r.PUT("/users/:id/payment-processors/:processor/default", handler.User.SetDefaultPaymentProcessor)
 	// DEBUG specific endpoints
-	router.GET("/health", health)
-
-	return router
+	r.GET("/health", handler.Health.Live)
+	r.GET("/ready", handler.Health.Ready)
+	r.GET("/openapi.json", routes.Spec().Handler)
 }
 
-func health(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO(coyle): actually check something
-	fmt.Fprintln(w, "OK")
+// sweepTokens periodically removes expired tokens
+func sweepTokens(ctx context.Context, db *mongodb.Client, logger log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := db.SweepExpiredTokens()
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to sweep expired tokens", "err", err)
+			continue
+		}
+
+		level.Debug(logger).Log("msg", "swept expired tokens", "removed", n)
+	}
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/server/routes"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	spec := routes.Spec()
+	registered := map[string]bool{}
+
+	record := func(method, route string, h httprouter.Handle) httprouter.Handle {
+		registered[method+" "+route] = true
+		assert.NotNil(t, spec.Operation(method, route), "%s %s is not documented in routes.Spec", method, route)
+		return h
+	}
+	register(routes.NewRouter(httprouter.New(), record), &routes.Handler{})
+
+	toRoute := strings.NewReplacer("{", ":", "}", "")
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, registered[strings.ToUpper(method)+" "+toRoute.Replace(path)], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
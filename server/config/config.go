@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/coyle/bridge/server/purge"
+	"github.com/coyle/bridge/server/quota"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// Config contains every setting of the bridge server. Values are layered: defaults, then the
+// config file, then environment variables, then flags.
+type Config struct {
+	Listen string `yaml:"listen" toml:"listen"`
+	// MetricsListen is the address /metrics is served on, apart from the API. Empty disables it.
+	MetricsListen string      `yaml:"metricsListen" toml:"metricsListen"`
+	URL           string      `yaml:"url" toml:"url"`
+	LogLevel      string      `yaml:"logLevel" toml:"logLevel"`
+	Mongo         Mongo       `yaml:"mongo" toml:"mongo"`
+	TLS           TLS         `yaml:"tls" toml:"tls"`
+	Mailer        Mailer      `yaml:"mailer" toml:"mailer"`
+	Engine        Engine      `yaml:"engine" toml:"engine"`
+	Quotas        quota.Tiers `yaml:"quotas" toml:"quotas"`
+	CORS          CORS        `yaml:"cors" toml:"cors"`
+	RateLimit     RateLimit   `yaml:"rateLimit" toml:"rateLimit"`
+	Purge         Purge       `yaml:"purge" toml:"purge"`
+	Payments      Payments    `yaml:"payments" toml:"payments"`
+	// ShutdownTimeout is how long in-flight requests are given to finish on SIGINT or SIGTERM
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+}
+
+// Mongo configures the database connection
+type Mongo struct {
+	URL      string `yaml:"url" toml:"url"`
+	Database string `yaml:"database" toml:"database"`
+	// ConnectTimeout is how long to keep retrying the first connection. Zero fails fast.
+	ConnectTimeout Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+}
+
+// TLS configures HTTPS. It is disabled unless both files are set. The files are reloaded when
+// they change or the server receives SIGHUP.
+type TLS struct {
+	Cert string `yaml:"cert" toml:"cert"`
+	Key  string `yaml:"key" toml:"key"`
+	// RedirectListen is an address on which plain HTTP requests are redirected to HTTPS
+	RedirectListen string `yaml:"redirectListen" toml:"redirectListen"`
+}
+
+// Enabled reports whether the server should listen with TLS
+func (t TLS) Enabled() bool {
+	return t.Cert != ""
+}
+
+// Mailer configures outgoing email. Mail is sent with SMTP if it is set, appended to File if
+// that is set instead, and otherwise logged.
+type Mailer struct {
+	SMTP string `yaml:"smtp" toml:"smtp"`
+	From string `yaml:"from" toml:"from"`
+	// File is the path of a file to append messages to during development
+	File string `yaml:"file" toml:"file"`
+}
+
+// Engine locates the storage engine. Readiness checks skip it unless URL is set.
+type Engine struct {
+	URL string `yaml:"url" toml:"url"`
+}
+
+// CORS configures which browser origins may call the API. It is disabled unless AllowedOrigins
+// is set.
+type CORS struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowedOrigins"`
+	AllowedMethods []string `yaml:"allowedMethods" toml:"allowedMethods"`
+	AllowedHeaders []string `yaml:"allowedHeaders" toml:"allowedHeaders"`
+	ExposedHeaders []string `yaml:"exposedHeaders" toml:"exposedHeaders"`
+	MaxAge         Duration `yaml:"maxAge" toml:"maxAge"`
+}
+
+// RateLimit configures where rate limit buckets are kept
+type RateLimit struct {
+	Store          string `yaml:"store" toml:"store"`
+	TrustForwarded bool   `yaml:"trustForwarded" toml:"trustForwarded"`
+}
+
+// Purge configures the deletion of deactivated accounts
+type Purge struct {
+	GracePeriod Duration `yaml:"gracePeriod" toml:"gracePeriod"`
+}
+
+// Payments configures the payment processors users can register with. A processor is only
+// offered if it is configured.
+type Payments struct {
+	// StripeKey is the secret API key of the Stripe account customers are created in
+	StripeKey string `yaml:"stripeKey" toml:"stripeKey"`
+	// Fake offers an in-memory processor for development. It must not be enabled in production.
+	Fake bool `yaml:"fake" toml:"fake"`
+}
+
+// Duration is a time.Duration written as a string such as "720h" in config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses the duration
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	d.Duration = v
+
+	return nil
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() Config {
+	return Config{
+		Listen:        ":8080",
+		MetricsListen: "localhost:9090",
+		URL:           "http://localhost:8080",
+		LogLevel:      "info",
+		Mongo: Mongo{
+			URL:            "localhost",
+			Database:       mongodb.DefaultDatabase,
+			ConnectTimeout: Duration{time.Minute},
+		},
+		Quotas: quota.DefaultTiers(),
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-TOTP-Code", "X-PubKey", "X-Signature", "X-Request-ID"},
+			ExposedHeaders: []string{"Retry-After", "X-Request-ID"},
+			MaxAge:         Duration{10 * time.Minute},
+		},
+		RateLimit:       RateLimit{Store: "memory"},
+		Purge:           Purge{GracePeriod: Duration{purge.DefaultGracePeriod}},
+		ShutdownTimeout: Duration{30 * time.Second},
+	}
+}
+
+// env maps environment variables to the settings they override
+var env = []struct {
+	name  string
+	apply func(c *Config, v string) error
+}{
+	{"LISTEN_ADDR", func(c *Config, v string) error { c.Listen = v; return nil }},
+	{"METRICS_ADDR", func(c *Config, v string) error { c.MetricsListen = v; return nil }},
+	{"BRIDGE_URL", func(c *Config, v string) error { c.URL = v; return nil }},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"MONGO", func(c *Config, v string) error { c.Mongo.URL = v; return nil }},
+	{"MONGO_DATABASE", func(c *Config, v string) error { c.Mongo.Database = v; return nil }},
+	{"MONGO_CONNECT_TIMEOUT", func(c *Config, v string) error { return c.Mongo.ConnectTimeout.UnmarshalText([]byte(v)) }},
+	{"SHUTDOWN_TIMEOUT", func(c *Config, v string) error { return c.ShutdownTimeout.UnmarshalText([]byte(v)) }},
+	{"TLS_CERT", func(c *Config, v string) error { c.TLS.Cert = v; return nil }},
+	{"TLS_KEY", func(c *Config, v string) error { c.TLS.Key = v; return nil }},
+	{"TLS_REDIRECT_ADDR", func(c *Config, v string) error { c.TLS.RedirectListen = v; return nil }},
+	{"ENGINE_URL", func(c *Config, v string) error { c.Engine.URL = v; return nil }},
+	{"SMTP", func(c *Config, v string) error { c.Mailer.SMTP = v; return nil }},
+	{"MAIL_FROM", func(c *Config, v string) error { c.Mailer.From = v; return nil }},
+	{"MAIL_FILE", func(c *Config, v string) error { c.Mailer.File = v; return nil }},
+	{"CORS_ALLOWED_ORIGINS", func(c *Config, v string) error { c.CORS.AllowedOrigins = split(v); return nil }},
+	{"CORS_ALLOWED_METHODS", func(c *Config, v string) error { c.CORS.AllowedMethods = split(v); return nil }},
+	{"CORS_ALLOWED_HEADERS", func(c *Config, v string) error { c.CORS.AllowedHeaders = split(v); return nil }},
+	{"RATE_LIMIT_STORE", func(c *Config, v string) error { c.RateLimit.Store = v; return nil }},
+	{"TRUST_X_FORWARDED_FOR", func(c *Config, v string) (err error) {
+		c.RateLimit.TrustForwarded, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"PURGE_GRACE_PERIOD", func(c *Config, v string) error { return c.Purge.GracePeriod.UnmarshalText([]byte(v)) }},
+	{"STRIPE_KEY", func(c *Config, v string) error { c.Payments.StripeKey = v; return nil }},
+}
+
+// Load builds the configuration from the config file named by the -config flag, the
+// environment as read by getenv, and the remaining flags in args. The result is validated.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+
+	flags := []struct {
+		name  string
+		usage string
+		dst   *string
+	}{
+		{"listen", "address to listen on", &c.Listen},
+		{"metrics-listen", "address to serve metrics on, apart from the API", &c.MetricsListen},
+		{"url", "public URL of the bridge, used in emailed links", &c.URL},
+		{"log-level", "one of debug, info, warn, or error", &c.LogLevel},
+		{"mongo-url", "MongoDB connection URL", &c.Mongo.URL},
+		{"mongo-database", "MongoDB database name", &c.Mongo.Database},
+		{"tls-cert", "path to the TLS certificate", &c.TLS.Cert},
+		{"tls-key", "path to the TLS private key", &c.TLS.Key},
+		{"tls-redirect", "address to redirect plain HTTP to HTTPS from", &c.TLS.RedirectListen},
+	}
+
+	fs := flag.NewFlagSet("bridge-server", flag.ContinueOnError)
+	file := fs.String("config", getenv("BRIDGE_CONFIG"), "path to a YAML or TOML config file")
+	values := make([]*string, len(flags))
+	for i, f := range flags {
+		values[i] = fs.String(f.name, "", f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *file != "" {
+		if err := c.loadFile(*file); err != nil {
+			return c, err
+		}
+	}
+
+	for _, e := range env {
+		if v := getenv(e.name); v != "" {
+			if err := e.apply(&c, v); err != nil {
+				return c, fmt.Errorf("config: %s: %v", e.name, err)
+			}
+		}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for i, f := range flags {
+		if set[f.name] {
+			*f.dst = *values[i]
+		}
+	}
+
+	return c, c.Validate()
+}
+
+// loadFile decodes the file over the current values, choosing the format by extension
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, c)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(b), c); err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown field %q", md.Undecoded()[0].String())
+		}
+	default:
+		return fmt.Errorf("config: %s: unsupported format, use .yaml, .yml, or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks every setting and returns an error listing all that are invalid
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Listen != "", "listen is required")
+	check(c.MetricsListen == "" || c.MetricsListen != c.Listen, "metricsListen must differ from listen")
+	check(validURL(c.URL, "http", "https"), "url must be an http or https URL, got %q", c.URL)
+	check(contains([]string{"debug", "info", "warn", "error"}, c.LogLevel), "logLevel must be one of debug, info, warn, or error, got %q", c.LogLevel)
+	check(c.Mongo.URL != "", "mongo.url is required")
+	check(c.Mongo.Database != "", "mongo.database is required")
+	check(c.Mongo.ConnectTimeout.Duration >= 0, "mongo.connectTimeout may not be negative")
+	check(c.ShutdownTimeout.Duration > 0, "shutdownTimeout must be positive")
+	check((c.TLS.Cert == "") == (c.TLS.Key == ""), "tls.cert and tls.key must be set together")
+	check(c.TLS.RedirectListen == "" || c.TLS.Enabled(), "tls.redirectListen requires tls.cert and tls.key")
+	if c.Mailer.SMTP != "" {
+		check(validURL(c.Mailer.SMTP, "smtp"), "mailer.smtp must be an smtp:// URL")
+		check(c.Mailer.From != "", "mailer.from is required when mailer.smtp is set")
+		check(c.Mailer.File == "", "mailer.smtp and mailer.file may not both be set")
+	}
+	check(c.Quotas.Free.Storage >= 0 && c.Quotas.Free.Transfer >= 0, "quotas.free may not be negative")
+	check(c.Quotas.Paid.Storage >= 0 && c.Quotas.Paid.Transfer >= 0, "quotas.paid may not be negative")
+	check(c.Engine.URL == "" || validURL(c.Engine.URL, "http", "https"), "engine.url must be an http or https URL, got %q", c.Engine.URL)
+	for _, o := range c.CORS.AllowedOrigins {
+		check(o == "*" || validURL(o, "http", "https"), "cors.allowedOrigins: %q is not \"*\" or an http(s) origin", o)
+	}
+	for _, m := range c.CORS.AllowedMethods {
+		check(m != "" && strings.ToUpper(m) == m, "cors.allowedMethods: %q is not an upper case method", m)
+	}
+	check(len(c.CORS.AllowedOrigins) == 0 || len(c.CORS.AllowedMethods) > 0, "cors.allowedMethods is required when cors.allowedOrigins is set")
+	check(c.CORS.MaxAge.Duration >= 0, "cors.maxAge may not be negative")
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "mongo", "rateLimit.store must be memory or mongo, got %q", c.RateLimit.Store)
+	check(c.Purge.GracePeriod.Duration > 0, "purge.gracePeriod must be positive")
+
+	if len(problems) > 0 {
+		return errors.New("config: invalid settings:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+func validURL(s string, schemes ...string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Host != "" && contains(schemes, u.Scheme)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
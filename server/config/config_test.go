@@ -0,0 +1,191 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/server/quota"
+)
+
+const yamlConfig = `
+listen: ":9000"
+mongo:
+  url: mongodb://db:27017
+  database: bridge-test
+quotas:
+  free:
+    storage: 100
+cors:
+  allowedOrigins: ["https://app.storj.io"]
+  maxAge: 1h
+purge:
+  gracePeriod: 48h
+payments:
+  stripeKey: sk_test
+`
+
+const tomlConfig = `
+listen = ":9000"
+
+[mongo]
+url = "mongodb://db:27017"
+database = "bridge-test"
+
+[quotas.free]
+storage = 100
+
+[cors]
+allowedOrigins = ["https://app.storj.io"]
+maxAge = "1h"
+
+[purge]
+gracePeriod = "48h"
+
+[payments]
+stripeKey = "sk_test"
+`
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, contents := range map[string]string{"bridge.yaml": yamlConfig, "bridge.toml": tomlConfig} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+		c, err := Load([]string{"-config", path}, noEnv)
+		assert.NoError(t, err, name)
+
+		assert.Equal(t, ":9000", c.Listen, name)
+		assert.Equal(t, "mongodb://db:27017", c.Mongo.URL, name)
+		assert.Equal(t, "bridge-test", c.Mongo.Database, name)
+		assert.Equal(t, quota.Limits{Storage: 100, Transfer: 25 * quota.GB}, c.Quotas.Free, name)
+		assert.Equal(t, []string{"https://app.storj.io"}, c.CORS.AllowedOrigins, name)
+		assert.Equal(t, time.Hour, c.CORS.MaxAge.Duration, name)
+		assert.Equal(t, 48*time.Hour, c.Purge.GracePeriod.Duration, name)
+		assert.Equal(t, Payments{StripeKey: "sk_test"}, c.Payments, name)
+		assert.Equal(t, "info", c.LogLevel, "unset values keep their defaults")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bridge.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(yamlConfig), 0600))
+
+	env := map[string]string{
+		"BRIDGE_CONFIG":        path,
+		"LISTEN_ADDR":          ":7000",
+		"MONGO":                "mongodb://env:27017",
+		"CORS_ALLOWED_ORIGINS": "https://a.storj.io, https://b.storj.io",
+	}
+
+	c, err := Load([]string{"-listen", ":6000"}, func(k string) string { return env[k] })
+	assert.NoError(t, err)
+
+	assert.Equal(t, ":6000", c.Listen, "flags override the environment")
+	assert.Equal(t, "mongodb://env:27017", c.Mongo.URL, "the environment overrides the file")
+	assert.Equal(t, "bridge-test", c.Mongo.Database, "the file overrides defaults")
+	assert.Equal(t, []string{"https://a.storj.io", "https://b.storj.io"}, c.CORS.AllowedOrigins)
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		modify   func(c *Config)
+		expected string
+	}{
+		{
+			name:   "defaults are valid",
+			modify: func(c *Config) {},
+		},
+		{
+			name:     "unknown log level",
+			modify:   func(c *Config) { c.LogLevel = "loud" },
+			expected: `logLevel must be one of debug, info, warn, or error, got "loud"`,
+		},
+		{
+			name:     "metrics on the api listener",
+			modify:   func(c *Config) { c.MetricsListen = c.Listen },
+			expected: "metricsListen must differ from listen",
+		},
+		{
+			name:     "tls key without cert",
+			modify:   func(c *Config) { c.TLS.Key = "key.pem" },
+			expected: "tls.cert and tls.key must be set together",
+		},
+		{
+			name:     "redirect without tls",
+			modify:   func(c *Config) { c.TLS.RedirectListen = ":80" },
+			expected: "tls.redirectListen requires tls.cert and tls.key",
+		},
+		{
+			name:     "smtp without sender",
+			modify:   func(c *Config) { c.Mailer.SMTP = "smtp://mail.storj.io:25" },
+			expected: "mailer.from is required when mailer.smtp is set",
+		},
+		{
+			name: "smtp and file",
+			modify: func(c *Config) {
+				c.Mailer.SMTP, c.Mailer.From, c.Mailer.File = "smtp://mail.storj.io:25", "bridge@storj.io", "mail.txt"
+			},
+			expected: "mailer.smtp and mailer.file may not both be set",
+		},
+		{
+			name:     "invalid origin",
+			modify:   func(c *Config) { c.CORS.AllowedOrigins = []string{"storj.io"} },
+			expected: `cors.allowedOrigins: "storj.io" is not "*" or an http(s) origin`,
+		},
+		{
+			name:     "lower case method",
+			modify:   func(c *Config) { c.CORS.AllowedMethods = []string{"get"} },
+			expected: `cors.allowedMethods: "get" is not an upper case method`,
+		},
+		{
+			name:     "unknown rate limit store",
+			modify:   func(c *Config) { c.RateLimit.Store = "redis" },
+			expected: `rateLimit.store must be memory or mongo, got "redis"`,
+		},
+	}
+
+	for _, c := range cases {
+		cfg := Default()
+		c.modify(&cfg)
+
+		err := cfg.Validate()
+		if c.expected == "" {
+			assert.NoError(t, err, c.name)
+			continue
+		}
+		if assert.Error(t, err, c.name) {
+			assert.Contains(t, err.Error(), c.expected, c.name)
+		}
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, contents := range map[string]string{"bridge.yaml": "lisen: \":9000\"\n", "bridge.toml": "lisen = \":9000\"\n"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+		_, err := Load([]string{"-config", path}, noEnv)
+		assert.Error(t, err, name)
+	}
+}
+
+func noEnv(string) string {
+	return ""
+}
@@ -0,0 +1,116 @@
+// Package cors lets browser applications served from other origins call the API
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coyle/bridge/server/routes/apierror"
+)
+
+// Policy lists what cross-origin requests may do. An origin is "*", an exact origin such as
+// https://app.storj.io, or a wildcard subdomain such as https://*.storj.io.
+type Policy struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// Handler wraps next so preflight requests from allowed origins are answered directly and
+// other requests from them carry CORS headers. Without allowed origins next is returned as is.
+func Handler(p Policy, next http.Handler) http.Handler {
+	if len(p.AllowedOrigins) == 0 {
+		return next
+	}
+
+	methods := strings.Join(p.AllowedMethods, ", ")
+	headers := strings.Join(p.AllowedHeaders, ", ")
+	exposed := strings.Join(p.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(p.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wildcard := p.allows(origin)
+		if !allowed {
+			if preflight {
+				apierror.Write(w, apierror.New(http.StatusForbidden, "origin not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wildcard {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if !preflight {
+			if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", methods)
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if p.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allows reports whether the origin may make requests, and whether that is because every
+// origin may
+func (p Policy) allows(origin string) (allowed, wildcard bool) {
+	for _, o := range p.AllowedOrigins {
+		switch {
+		case o == "*":
+			return true, true
+		case strings.EqualFold(o, origin):
+			return true, false
+		case matchSubdomain(o, origin):
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+// matchSubdomain reports whether origin is a subdomain of a pattern such as https://*.storj.io
+func matchSubdomain(pattern, origin string) bool {
+	i := strings.Index(pattern, "://*.")
+	if i < 0 {
+		return false
+	}
+
+	prefix, suffix := strings.ToLower(pattern[:i+3]), strings.ToLower(pattern[i+4:])
+	origin = strings.ToLower(origin)
+
+	return strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+		len(origin) > len(prefix)+len(suffix) && !strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:@")
+}
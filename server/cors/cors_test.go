@@ -0,0 +1,130 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	policy := Policy{
+		AllowedOrigins: []string{"https://app.storj.io", "https://*.storj.dev"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "X-PubKey", "X-Signature"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	cases := []struct {
+		name      string
+		policy    Policy
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		headers   map[string]string
+	}{
+		{
+			name:      "preflight from allowed origin",
+			policy:    policy,
+			method:    "OPTIONS",
+			origin:    "https://app.storj.io",
+			preflight: true,
+			status:    http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.storj.io",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Authorization, X-PubKey, X-Signature",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:      "preflight from subdomain",
+			policy:    policy,
+			method:    "OPTIONS",
+			origin:    "https://upload.storj.dev",
+			preflight: true,
+			status:    http.StatusNoContent,
+			headers:   map[string]string{"Access-Control-Allow-Origin": "https://upload.storj.dev"},
+		},
+		{
+			name:      "preflight from other origin",
+			policy:    policy,
+			method:    "OPTIONS",
+			origin:    "https://evil.example",
+			preflight: true,
+			status:    http.StatusForbidden,
+			headers:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:      "subdomain pattern does not match lookalike",
+			policy:    policy,
+			method:    "OPTIONS",
+			origin:    "https://evil.example/x.storj.dev",
+			preflight: true,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:   "request from allowed origin",
+			policy: policy,
+			method: "GET",
+			origin: "https://app.storj.io",
+			status: http.StatusOK,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.storj.io",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+				"Access-Control-Allow-Methods":  "",
+			},
+		},
+		{
+			name:    "request from other origin",
+			policy:  policy,
+			method:  "GET",
+			origin:  "https://evil.example",
+			status:  http.StatusOK,
+			headers: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:      "any origin",
+			policy:    Policy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+			method:    "OPTIONS",
+			origin:    "https://anywhere.example",
+			preflight: true,
+			status:    http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+				"Access-Control-Max-Age":      "",
+			},
+		},
+		{
+			name:      "disabled",
+			policy:    Policy{},
+			method:    "OPTIONS",
+			origin:    "https://app.storj.io",
+			preflight: true,
+			status:    http.StatusOK,
+			headers:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/buckets", nil)
+		r.Header.Set("Origin", c.origin)
+		if c.preflight {
+			r.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		w := httptest.NewRecorder()
+
+		Handler(c.policy, next).ServeHTTP(w, r)
+
+		assert.Equal(t, c.status, w.Code, c.name)
+		for k, v := range c.headers {
+			assert.Equal(t, v, w.Header().Get(k), c.name+": "+k)
+		}
+	}
+}
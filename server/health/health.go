@@ -0,0 +1,223 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+const (
+	// StatusOK is reported for a dependency that responded in time
+	StatusOK = "ok"
+	// StatusDown is reported for a dependency that failed or timed out
+	StatusDown = "down"
+	// StatusDegraded is reported overall when only non-critical dependencies are down
+	StatusDegraded = "degraded"
+)
+
+// Check probes a single dependency. The instance is not ready while a Critical check fails.
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    func() error
+}
+
+// Result is the outcome of a Check. Error is logged rather than served, since it can name
+// internal addresses.
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"-"`
+}
+
+// Report is the body of a readiness response
+type Report struct {
+	Status       string            `json:"status"`
+	Dependencies map[string]Result `json:"dependencies"`
+}
+
+// Health serves liveness and readiness probes
+type Health struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewServer returns a Health server that gives each check up to timeout to respond
+func NewServer(timeout time.Duration, checks ...Check) *Health {
+	return &Health{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// Live reports that the process is up and serving requests
+func (h *Health) Live(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": StatusOK})
+}
+
+// Ready runs every check and responds 503 if a critical dependency is down. Only the status
+// of each check is served; why a check failed is logged.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	report := h.Run()
+
+	for name, result := range report.Dependencies {
+		if result.Error != "" {
+			level.Warn(logger).Log("msg", "dependency check failed", "dependency", name, "critical", result.Critical, "err", result.Error)
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// Run probes every dependency concurrently
+func (h *Health) Run() Report {
+	report := Report{
+		Status:       StatusOK,
+		Dependencies: make(map[string]Result, len(h.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			result := h.probe(c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[c.Name] = result
+		}(c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Dependencies {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	return report
+}
+
+// probe runs the check, giving up after the timeout. A probe that times out is left to
+// finish in the background.
+func (h *Health) probe(c Check) Result {
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- c.Probe() }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-time.After(h.timeout):
+		err = fmt.Errorf("timed out after %s", h.timeout)
+	}
+
+	result := Result{
+		Status:    StatusOK,
+		Critical:  c.Critical,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// mailerProbeInterval is how long the result of a mailer probe is reused
+const mailerProbeInterval = time.Minute
+
+// Mongo checks the database answers a ping within timeout. The bridge can not serve anything
+// without it.
+func Mongo(db *mongodb.Client, timeout time.Duration) Check {
+	return Check{Name: "mongo", Critical: true, Probe: func() error { return db.Ping(timeout) }}
+}
+
+// Pinger is implemented by dependencies that can check their own connectivity
+type Pinger interface {
+	Ping() error
+}
+
+// Mailer checks the mail relay is reachable. Mail is sent in the background so an outage
+// does not stop the instance serving requests. The result is reused for a minute so that
+// frequent readiness probes do not each open a connection to the relay.
+func Mailer(p Pinger) Check {
+	return Check{Name: "mailer", Probe: cache(p.Ping, mailerProbeInterval)}
+}
+
+// cache returns a probe that reuses the result of probe for ttl. Only one probe runs at a
+// time; callers arriving while it runs wait for its result.
+func cache(probe func() error, ttl time.Duration) func() error {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+
+		last = probe()
+		checked = time.Now()
+
+		return last
+	}
+}
+
+// Engine checks the storage engine answers HTTP requests at url. Account and billing
+// endpoints keep working without it, so it is not critical.
+func Engine(client *http.Client, url string) Check {
+	return Check{
+		Name: "engine",
+		Probe: func() error {
+			resp, err := client.Get(url)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode >= 500 {
+				return fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReady(t *testing.T) {
+	ok := func() error { return nil }
+	fail := func() error { return errors.New("connection refused") }
+	hang := func() error { time.Sleep(time.Second); return nil }
+
+	cases := []struct {
+		name           string
+		checks         []Check
+		expectedCode   int
+		expectedStatus string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "all dependencies up",
+			checks:         []Check{{Name: "mongo", Critical: true, Probe: ok}, {Name: "mailer", Probe: ok}},
+			expectedCode:   http.StatusOK,
+			expectedStatus: StatusOK,
+		},
+		{
+			name:           "non-critical dependency down",
+			checks:         []Check{{Name: "mongo", Critical: true, Probe: ok}, {Name: "mailer", Probe: fail}},
+			expectedCode:   http.StatusOK,
+			expectedStatus: StatusDegraded,
+			expectedErrors: map[string]string{"mailer": "connection refused"},
+		},
+		{
+			name:           "critical dependency down",
+			checks:         []Check{{Name: "mongo", Critical: true, Probe: fail}, {Name: "mailer", Probe: fail}},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: StatusDown,
+			expectedErrors: map[string]string{"mongo": "connection refused", "mailer": "connection refused"},
+		},
+		{
+			name:           "critical dependency times out",
+			checks:         []Check{{Name: "mongo", Critical: true, Probe: hang}},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: StatusDown,
+			expectedErrors: map[string]string{"mongo": "timed out after 50ms"},
+		},
+	}
+
+	for _, c := range cases {
+		h := NewServer(50*time.Millisecond, c.checks...)
+		w := httptest.NewRecorder()
+		h.Ready(w, httptest.NewRequest("GET", "/ready", nil), nil)
+
+		assert.NotContains(t, w.Body.String(), "connection refused", "failures are not served")
+
+		report := Report{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&report), c.name)
+
+		assert.Equal(t, c.expectedCode, w.Code, c.name)
+		assert.Equal(t, c.expectedStatus, report.Status, c.name)
+		assert.Len(t, report.Dependencies, len(c.checks), c.name)
+
+		for name, result := range h.Run().Dependencies {
+			assert.Equal(t, c.expectedErrors[name], result.Error, c.name)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	probe := cache(func() error {
+		calls++
+		return errors.New("connection refused")
+	}, 50*time.Millisecond)
+
+	assert.EqualError(t, probe(), "connection refused")
+	assert.EqualError(t, probe(), "connection refused")
+	assert.Equal(t, 1, calls, "the result is reused within the ttl")
+
+	time.Sleep(60 * time.Millisecond)
+	probe()
+	assert.Equal(t, 2, calls, "the dependency is probed again once the result expires")
+}
@@ -0,0 +1,115 @@
+package https
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		method   string
+		host     string
+		port     string
+		target   string
+		expected string
+		status   int
+	}{
+		{"GET", "bridge.storj.io", "443", "/users/a?x=1", "https://bridge.storj.io/users/a?x=1", http.StatusMovedPermanently},
+		{"GET", "bridge.storj.io:80", "", "/", "https://bridge.storj.io/", http.StatusMovedPermanently},
+		{"POST", "localhost:8080", "8443", "/users", "https://localhost:8443/users", http.StatusPermanentRedirect},
+		{"HEAD", "[::1]", "8443", "/health", "https://[::1]:8443/health", http.StatusMovedPermanently},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		r.Host = c.host
+		w := httptest.NewRecorder()
+
+		Redirect(c.port).ServeHTTP(w, r)
+
+		assert.Equal(t, c.status, w.Code, c.host)
+		assert.Equal(t, c.expected, w.Header().Get("Location"), c.host)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "https")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeKeyPair(t, certFile, keyFile, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "first", commonName(t, r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := make(chan os.Signal)
+	go r.Watch(ctx, time.Hour, trigger, log.NewNopLogger())
+
+	// The second send on the unbuffered trigger only completes once the first reload is done
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	trigger <- os.Interrupt
+	trigger <- os.Interrupt
+	assert.Equal(t, "first", commonName(t, r), "a broken pair keeps the current certificate")
+
+	writeKeyPair(t, certFile, keyFile, "second")
+	trigger <- os.Interrupt
+	trigger <- os.Interrupt
+	assert.Equal(t, "second", commonName(t, r))
+
+	future := time.Now().Add(time.Hour)
+	writeKeyPair(t, certFile, keyFile, "third")
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+	assert.True(t, r.changed())
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	cert, err := r.GetCertificate(nil)
+	assert.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+func writeKeyPair(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
@@ -0,0 +1,132 @@
+// Package https serves the bridge over TLS with certificates that can be replaced while the
+// server is running, and redirects plain HTTP requests to it.
+package https
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Reloader holds the current certificate for a certificate and key file pair. Connections
+// already established keep the certificate they were made with.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewReloader loads the certificate and key files and returns a Reloader serving them
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the certificate and key files again. On failure the current certificate is kept.
+func (r *Reloader) Reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.modTime = modTime
+
+	return nil
+}
+
+// GetCertificate returns the current certificate. Set it as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Config returns a TLS configuration serving the current certificate
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// Watch reloads the certificate whenever either file has changed, checking every interval, and
+// whenever a value is received on trigger, until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, trigger <-chan os.Signal, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		}
+
+		if err := r.Reload(); err != nil {
+			level.Error(logger).Log("msg", "failed to reload certificate, keeping the current one", "err", err, "cert", r.certFile)
+			continue
+		}
+
+		level.Info(logger).Log("msg", "reloaded certificate", "cert", r.certFile)
+	}
+}
+
+// changed reports whether either file has been modified since the last successful reload
+func (r *Reloader) changed() bool {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return !modTime.Equal(r.modTime)
+}
+
+// lastModified returns the later modification time of the certificate and key files
+func (r *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
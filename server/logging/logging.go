@@ -0,0 +1,122 @@
+// Package logging gives every request its own logger carrying the request ID, method, route,
+// and, once authenticated, the user, so that each line a handler writes can be traced back
+// to the request that caused it.
+package logging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// RequestIDHeader carries the request ID. A valid ID sent by the client or a proxy is kept,
+// otherwise one is generated. Either way it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from clients so they cannot bloat every log line
+const maxRequestIDLength = 128
+
+type contextKey struct{}
+
+// scope holds the logger for one request. It is shared by pointer so that authentication,
+// which happens inside the handler, can add the user to every line logged after it.
+type scope struct {
+	id     string
+	logger log.Logger
+}
+
+// NewContext returns a copy of ctx carrying logger as the request logger
+func NewContext(ctx context.Context, id string, logger log.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{id: id, logger: logger})
+}
+
+// FromContext returns the request logger, or a logger that discards everything if ctx has none.
+// The logger reflects values added by SetUser even after it has been returned.
+func FromContext(ctx context.Context) log.Logger {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return log.NewNopLogger()
+	}
+
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		return s.logger.Log(keyvals...)
+	})
+}
+
+// RequestID returns the ID of the request, or "" if ctx has none
+func RequestID(ctx context.Context) string {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		return s.id
+	}
+
+	return ""
+}
+
+// SetUser adds the authenticated user to the request logger
+func SetUser(ctx context.Context, id string) {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		s.logger = log.With(s.logger, "user", id)
+	}
+}
+
+// Middleware returns a function that wraps the handle for a method and route pattern so that
+// it runs with a request logger derived from logger, and logs the outcome of every request
+func Middleware(logger log.Logger) func(method, route string, h httprouter.Handle) httprouter.Handle {
+	return func(method, route string, h httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			start := time.Now()
+
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := NewContext(r.Context(), id, log.With(logger, "request_id", id, "method", method, "route", route))
+			sw := NewStatusWriter(w)
+
+			h(sw, r.WithContext(ctx), ps)
+
+			level.Info(FromContext(ctx)).Log("msg", "request handled", "status", sw.Status, "duration", time.Since(start))
+		}
+	}
+}
+
+// validRequestID reports whether id is short and limited to characters that are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// StatusWriter remembers the status code written by a handler, for middleware that reports it
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+}
+
+// NewStatusWriter wraps w. The status is 200 until the handler writes another.
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader records the status and writes it
+func (w *StatusWriter) WriteHeader(status int) {
+	w.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
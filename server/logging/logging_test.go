@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "propagated", header: "abc-123", expected: "abc-123"},
+		{name: "generated when missing", header: ""},
+		{name: "generated when unsafe", header: "bad id\nuser=admin"},
+		{name: "generated when too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		handle := Middleware(log.NewLogfmtLogger(&buf))("GET", "/users/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			logger := FromContext(r.Context())
+			SetUser(r.Context(), "user-1")
+			level.Error(logger).Log("msg", "failed")
+			w.WriteHeader(http.StatusTeapot)
+		})
+
+		r := httptest.NewRequest("GET", "/users/user-1", nil)
+		if c.header != "" {
+			r.Header.Set(RequestIDHeader, c.header)
+		}
+		w := httptest.NewRecorder()
+		handle(w, r, nil)
+
+		id := w.Header().Get(RequestIDHeader)
+		if c.expected != "" {
+			assert.Equal(t, c.expected, id, c.name)
+		} else {
+			assert.Len(t, id, 36, c.name)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if assert.Len(t, lines, 2, c.name) {
+			prefix := "request_id=" + id + " method=GET route=/users/:id user=user-1 level="
+			assert.Equal(t, prefix+"error msg=failed", lines[0], c.name)
+			assert.True(t, strings.HasPrefix(lines[1], prefix+"info msg=\"request handled\" status=418 "), c.name)
+		}
+	}
+}
+
+func TestFromContextWithoutLogger(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	assert.NoError(t, FromContext(r.Context()).Log("msg", "discarded"))
+	assert.Equal(t, "", RequestID(r.Context()))
+	SetUser(r.Context(), "user-1")
+}
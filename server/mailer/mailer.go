@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Message is a single outbound email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is the contract that all outbound email transports will need to adhere to
+type Mailer interface {
+	Send(m Message) error
+}
+
+// Log is a development Mailer that logs the recipient and subject of every message instead of
+// delivering it. Bodies are left out since they hold working account links; use File to keep
+// whole messages.
+type Log struct {
+	logger log.Logger
+}
+
+// NewLog returns a Mailer that logs messages
+func NewLog(logger log.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// Send logs the recipient and subject of the message
+func (l *Log) Send(m Message) error {
+	return level.Info(l.logger).Log("msg", "mail not sent, logging instead", "mail_to", m.To, "subject", m.Subject)
+}
+
+// File is a development Mailer that appends every message to a file
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a Mailer that appends messages to the file at path
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Send appends the message to the file
+func (f *File) Send(m Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fd, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	return write(fd, "", m)
+}
+
+// write renders the message in RFC 5322 format
+func write(w io.Writer, from string, m Message) error {
+	headers := []string{
+		fmt.Sprintf("To: %s", m.To),
+		fmt.Sprintf("Subject: %s", m.Subject),
+		fmt.Sprintf("Date: %s", time.Now().UTC().Format(time.RFC1123Z)),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+	if from != "" {
+		headers = append([]string{fmt.Sprintf("From: %s", from)}, headers...)
+	}
+
+	_, err := fmt.Fprintf(w, "%s\r\n\r\n%s\r\n", strings.Join(headers, "\r\n"), m.Body)
+	return err
+}
@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplates(t *testing.T) {
+	tmpl := NewTemplates("https://api.example.com")
+
+	cases := []struct {
+		name         string
+		render       func(to, token string) (Message, error)
+		expectedLink string
+	}{
+		{"activation", tmpl.Activation, "https://api.example.com/activations/abc123"},
+		{"deactivation", tmpl.Deactivation, "https://api.example.com/deactivations/abc123"},
+		{"password reset", tmpl.PasswordReset, "https://api.example.com/resets/abc123"},
+		{"email change", tmpl.EmailChange, "https://api.example.com/emails/abc123"},
+	}
+
+	for _, c := range cases {
+		m, err := c.render("test@storj.io", "abc123")
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, "test@storj.io", m.To, c.name)
+		assert.NotEmpty(t, m.Subject, c.name)
+		assert.Contains(t, m.Body, c.expectedLink, c.name)
+	}
+}
+
+func TestFileSend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mail.log")
+	f := NewFile(path)
+
+	assert.NoError(t, f.Send(Message{To: "a@storj.io", Subject: "first", Body: "one"}))
+	assert.NoError(t, f.Send(Message{To: "b@storj.io", Subject: "second", Body: "two"}))
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "Subject: "))
+	assert.Contains(t, string(b), "To: b@storj.io")
+}
+
+func TestLogSendOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLog(log.NewLogfmtLogger(&buf))
+
+	assert.NoError(t, l.Send(Message{To: "a@storj.io", Subject: "Reset", Body: "https://api.storj.io/resets/secret"}))
+	assert.Contains(t, buf.String(), "mail_to=a@storj.io")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestSMTPSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 test")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "DATA"):
+				text.PrintfLine("354 go ahead")
+				data, _ := text.ReadDotLines()
+				received <- strings.Join(data, "\n")
+				text.PrintfLine("250 ok")
+			case strings.HasPrefix(line, "QUIT"):
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	s, err := NewSMTP("smtp://"+ln.Addr().String(), "bridge@storj.io")
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Send(Message{To: "a@storj.io", Subject: "Hello", Body: "body"}))
+	data := <-received
+	assert.Contains(t, data, "Subject: Hello")
+	assert.Contains(t, data, "body")
+}
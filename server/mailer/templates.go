@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"bytes"
+	"text/template"
+)
+
+var (
+	activationTemplate = template.Must(template.New("activation").Parse(`Welcome to the bridge!
+
+Please confirm your email address to activate your account:
+
+{{.URL}}/activations/{{.Token}}
+
+If you did not create an account you can ignore this email.
+`))
+
+	deactivationTemplate = template.Must(template.New("deactivation").Parse(`We received a request to delete your bridge account.
+
+To confirm, visit the following link:
+
+{{.URL}}/deactivations/{{.Token}}
+
+If you did not request this your account will remain active and you can ignore this email.
+`))
+
+	emailChangeTemplate = template.Must(template.New("email").Parse(`We received a request to change the email address of your bridge account to this address.
+
+To confirm, visit the following link:
+
+{{.URL}}/emails/{{.Token}}
+
+If you did not request this you can ignore this email.
+`))
+
+	resetTemplate = template.Must(template.New("reset").Parse(`We received a request to reset your bridge password.
+
+To choose a new password, visit the following link:
+
+{{.URL}}/resets/{{.Token}}
+
+If you did not request a reset you can ignore this email.
+`))
+)
+
+// Templates renders the account lifecycle emails with links back to the bridge
+type Templates struct {
+	url string
+}
+
+// NewTemplates returns Templates whose links point at the bridge hosted at url
+func NewTemplates(url string) *Templates {
+	return &Templates{url: url}
+}
+
+// Activation returns the email that lets a user confirm their account
+func (t *Templates) Activation(to, token string) (Message, error) {
+	return t.render(activationTemplate, to, "Activate your account", token)
+}
+
+// Deactivation returns the email that lets a user confirm their account deletion
+func (t *Templates) Deactivation(to, token string) (Message, error) {
+	return t.render(deactivationTemplate, to, "Confirm account deletion", token)
+}
+
+// EmailChange returns the email that lets a user confirm their new email address
+func (t *Templates) EmailChange(to, token string) (Message, error) {
+	return t.render(emailChangeTemplate, to, "Confirm your new email address", token)
+}
+
+// PasswordReset returns the email that lets a user choose a new password
+func (t *Templates) PasswordReset(to, token string) (Message, error) {
+	return t.render(resetTemplate, to, "Reset your password", token)
+}
+
+func (t *Templates) render(tmpl *template.Template, to, subject, token string) (Message, error) {
+	buf := &bytes.Buffer{}
+	err := tmpl.Execute(buf, struct {
+		URL   string
+		Token string
+	}{t.url, token})
+
+	return Message{To: to, Subject: subject, Body: buf.String()}, err
+}
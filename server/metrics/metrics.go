@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/julienschmidt/httprouter"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// onlineWindow is how recently a contact must have been seen to count as online
+const onlineWindow = 15 * time.Minute
+
+// storedBytesMaxAge is how often the stored bytes total is recomputed across all instances
+const storedBytesMaxAge = 15 * time.Minute
+
+// Metrics holds the instruments exposed at /metrics
+type Metrics struct {
+	requests       metrics.Counter
+	latency        metrics.Histogram
+	dbLatency      metrics.Histogram
+	users          metrics.Gauge
+	buckets        metrics.Gauge
+	storedBytes    metrics.Gauge
+	onlineContacts metrics.Gauge
+	registry       *stdprometheus.Registry
+}
+
+// New returns Metrics registered with a new Prometheus registry, which also collects the
+// Go runtime and process metrics
+func New() *Metrics {
+	r := stdprometheus.NewRegistry()
+	r.MustRegister(stdprometheus.NewGoCollector())
+	r.MustRegister(stdprometheus.NewProcessCollector(stdprometheus.ProcessCollectorOpts{}))
+
+	counter := func(opts stdprometheus.CounterOpts, labels ...string) metrics.Counter {
+		cv := stdprometheus.NewCounterVec(opts, labels)
+		r.MustRegister(cv)
+		return kitprometheus.NewCounter(cv)
+	}
+	histogram := func(opts stdprometheus.HistogramOpts, labels ...string) metrics.Histogram {
+		hv := stdprometheus.NewHistogramVec(opts, labels)
+		r.MustRegister(hv)
+		return kitprometheus.NewHistogram(hv)
+	}
+	gauge := func(opts stdprometheus.GaugeOpts) metrics.Gauge {
+		gv := stdprometheus.NewGaugeVec(opts, nil)
+		r.MustRegister(gv)
+		return kitprometheus.NewGauge(gv)
+	}
+
+	return &Metrics{
+		registry: r,
+		requests: counter(stdprometheus.CounterOpts{
+			Namespace: "bridge",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route and status code.",
+		}, "method", "route", "code"),
+		latency: histogram(stdprometheus.HistogramOpts{
+			Namespace: "bridge",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, "method", "route"),
+		dbLatency: histogram(stdprometheus.HistogramOpts{
+			Namespace: "bridge",
+			Subsystem: "mongo",
+			Name:      "operation_duration_seconds",
+			Help:      "MongoDB client operation latency.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, "operation"),
+		users: gauge(stdprometheus.GaugeOpts{
+			Namespace: "bridge",
+			Name:      "users",
+			Help:      "Number of user accounts.",
+		}),
+		buckets: gauge(stdprometheus.GaugeOpts{
+			Namespace: "bridge",
+			Name:      "buckets",
+			Help:      "Number of buckets.",
+		}),
+		storedBytes: gauge(stdprometheus.GaugeOpts{
+			Namespace: "bridge",
+			Name:      "stored_bytes",
+			Help:      "Total size of all frames.",
+		}),
+		onlineContacts: gauge(stdprometheus.GaugeOpts{
+			Namespace: "bridge",
+			Name:      "contacts_online",
+			Help:      "Number of storage nodes seen in the last 15 minutes.",
+		}),
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// Instrument wraps the handle to count and time requests under the route pattern, so
+// /users/:id is one series rather than one per user
+func (m *Metrics) Instrument(method, route string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		sw := logging.NewStatusWriter(w)
+
+		h(sw, r, ps)
+
+		m.requests.With("method", method, "route", route, "code", strconv.Itoa(sw.Status)).Add(1)
+		m.latency.With("method", method, "route", route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDB records the latency of a mongodb.Client operation. Pass it to SetObserver.
+func (m *Metrics) ObserveDB(operation string, d time.Duration) {
+	m.dbLatency.With("operation", operation).Observe(d.Seconds())
+}
+
+// Track refreshes the business gauges every interval until ctx is done
+func (m *Metrics) Track(ctx context.Context, db *mongodb.Client, logger log.Logger, interval time.Duration) {
+	for {
+		stats, err := db.GetStats(time.Now().UTC().Add(-onlineWindow), storedBytesMaxAge)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to collect stats", "err", err)
+		} else {
+			m.users.Set(float64(stats.Users))
+			m.buckets.Set(float64(stats.Buckets))
+			m.storedBytes.Set(float64(stats.StoredBytes))
+			m.onlineContacts.Set(float64(stats.OnlineContacts))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
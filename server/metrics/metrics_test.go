@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	m := New()
+	router := httprouter.New()
+	router.GET("/metrics", m.Handler)
+
+	router.GET("/users/:id", m.Instrument("GET", "/users/:id", func(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+		if ps.ByName("id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	for _, path := range []string{"/users/a", "/users/b", "/users/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	m.ObserveDB("GetUser", 3*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, body, `bridge_http_requests_total{code="200",method="GET",route="/users/:id"} 2`)
+	assert.Contains(t, body, `bridge_http_requests_total{code="404",method="GET",route="/users/:id"} 1`)
+	assert.Contains(t, body, `bridge_http_request_duration_seconds_count{method="GET",route="/users/:id"} 3`)
+	assert.Contains(t, body, `bridge_mongo_operation_duration_seconds_count{operation="GetUser"} 1`)
+	assert.NotContains(t, body, `/users/a`, "series are keyed by route pattern, not path")
+}
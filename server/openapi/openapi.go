@@ -0,0 +1,325 @@
+// Package openapi builds an OpenAPI 3 description of the API. Schemas are derived by reflection
+// from the Go types that handlers decode and encode, so they follow the code as it changes.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Version is the OpenAPI specification version the documents follow
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API as a whole
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of one path keyed by lower case method
+type PathItem map[string]*Operation
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation describes one method on one path
+type Operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path, query, or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one response status of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema used to describe Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Route documents an operation registered on the router
+type Route struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// Stub marks routes that are registered but only answer with a placeholder
+	Stub bool
+	// Auth requires basic auth credentials, and a TOTP code for users with two-factor enabled
+	Auth bool
+	// Query lists the query parameters, all optional
+	Query []Parameter
+	// Body is a value of the type decoded from the request body, or nil if there is none
+	Body interface{}
+	// Status is the success status. Response is a value of the type encoded in the success
+	// body, or nil if there is none.
+	Status   int
+	Response interface{}
+	// ContentType is the success content type when it is not JSON
+	ContentType string
+}
+
+const (
+	jsonType  = "application/json"
+	basicAuth = "basicAuth"
+)
+
+// New documents the routes. Errors refer to the schema of errorBody.
+func New(info Info, errorBody interface{}, routes []Route) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				basicAuth: {
+					Type:        "http",
+					Scheme:      "basic",
+					Description: "Email and hex SHA-256 of the password. Users with two-factor enabled also send X-TOTP-Code.",
+				},
+			},
+		},
+	}
+
+	errorSchema := d.schema(reflect.TypeOf(errorBody))
+
+	for _, rt := range routes {
+		op := &Operation{
+			Summary:   rt.Summary,
+			Responses: map[string]Response{},
+		}
+		if rt.Tag != "" {
+			op.Tags = []string{rt.Tag}
+		}
+		if rt.Stub {
+			op.Description = "Not implemented yet. Responds with a plain text placeholder."
+		}
+
+		for _, name := range Params(rt.Path) {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		op.Parameters = append(op.Parameters, rt.Query...)
+
+		if rt.Auth {
+			op.Security = []map[string][]string{{basicAuth: {}}}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        "X-TOTP-Code",
+				In:          "header",
+				Description: "TOTP or recovery code, required when two-factor is enabled",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if rt.Body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{jsonType: {Schema: d.schema(reflect.TypeOf(rt.Body))}},
+			}
+		}
+
+		success := Response{Description: http.StatusText(rt.Status)}
+		switch {
+		case rt.ContentType != "":
+			success.Content = map[string]MediaType{rt.ContentType: {Schema: &Schema{Type: "string"}}}
+		case rt.Response != nil:
+			success.Content = map[string]MediaType{jsonType: {Schema: d.schema(reflect.TypeOf(rt.Response))}}
+		}
+		op.Responses[strconv.Itoa(rt.Status)] = success
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{jsonType: {Schema: errorSchema}},
+		}
+
+		p := Path(rt.Path)
+		if d.Paths[p] == nil {
+			d.Paths[p] = PathItem{}
+		}
+		d.Paths[p][strings.ToLower(rt.Method)] = op
+	}
+
+	return d
+}
+
+// Handler serves the document as JSON
+func (d *Document) Handler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", jsonType)
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(d)
+}
+
+// Operation returns the operation documented for the method and httprouter path, or nil
+func (d *Document) Operation(method, route string) *Operation {
+	return d.Paths[Path(route)][strings.ToLower(method)]
+}
+
+// Path converts an httprouter path such as /users/:id to an OpenAPI path such as /users/{id}
+func Path(route string) string {
+	var b strings.Builder
+	for i := 0; i < len(route); i++ {
+		if route[i] != ':' && route[i] != '*' {
+			b.WriteByte(route[i])
+			continue
+		}
+
+		end := strings.IndexByte(route[i:], '/')
+		if end < 0 {
+			end = len(route) - i
+		}
+		b.WriteString("{" + route[i+1:i+end] + "}")
+		i += end - 1
+	}
+
+	return b.String()
+}
+
+// Params returns the names of the parameters in an httprouter path
+func Params(route string) []string {
+	var names []string
+	for _, segment := range strings.Split(route, "/") {
+		if i := strings.IndexAny(segment, ":*"); i >= 0 {
+			names = append(names, segment[i+1:])
+		}
+	}
+
+	return names
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t. Named structs are added to the components and referenced.
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return d.object(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+// object describes the JSON encoding of a struct's exported fields
+func (d *Document) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range d.object(f.Type).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+
+		s.Properties[name] = d.schema(f.Type)
+	}
+
+	return s
+}
@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testError struct {
+	Code string `json:"error"`
+}
+
+type testItem struct {
+	ID       string            `json:"id"`
+	Size     int64             `json:"size"`
+	Tags     []string          `json:"tags,omitempty"`
+	Data     map[string]string `json:"data"`
+	Created  time.Time         `json:"created"`
+	Secret   string            `json:"-"`
+	Children []testItem        `json:"children"`
+	internal bool
+}
+
+func TestPath(t *testing.T) {
+	cases := []struct {
+		route  string
+		path   string
+		params []string
+	}{
+		{"/users", "/users", nil},
+		{"/users/:id/payment-processors/:processor", "/users/{id}/payment-processors/{processor}", []string{"id", "processor"}},
+		{"/frames:frame", "/frames{frame}", []string{"frame"}},
+		{"/static/*filepath", "/static/{filepath}", []string{"filepath"}},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.path, Path(c.route), c.route)
+		assert.Equal(t, c.params, Params(c.route), c.route)
+	}
+}
+
+func TestNew(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"}, testError{}, []Route{
+		{Method: "POST", Path: "/items/:id", Summary: "create", Auth: true, Body: testItem{}, Status: http.StatusCreated, Response: &testItem{}},
+		{Method: "GET", Path: "/items", Summary: "list", Status: http.StatusOK, Response: []testItem{}},
+		{Method: "GET", Path: "/metrics", Summary: "metrics", Status: http.StatusOK, ContentType: "text/plain"},
+	})
+
+	op := d.Operation("POST", "/items/:id")
+	if !assert.NotNil(t, op) {
+		return
+	}
+	assert.Equal(t, "#/components/schemas/openapi.testItem", op.RequestBody.Content[jsonType].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/openapi.testItem", op.Responses["201"].Content[jsonType].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/openapi.testError", op.Responses["default"].Content[jsonType].Schema.Ref)
+	assert.Equal(t, []map[string][]string{{basicAuth: {}}}, op.Security)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+
+	assert.Equal(t, "array", d.Operation("GET", "/items").Responses["200"].Content[jsonType].Schema.Type)
+	assert.Equal(t, "string", d.Operation("GET", "/metrics").Responses["200"].Content["text/plain"].Schema.Type)
+	assert.Nil(t, d.Operation("DELETE", "/items/:id"))
+
+	item := d.Components.Schemas["openapi.testItem"]
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, item.Properties["size"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, item.Properties["created"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, item.Properties["data"])
+	assert.Equal(t, "#/components/schemas/openapi.testItem", item.Properties["children"].Items.Ref)
+	assert.NotContains(t, item.Properties, "Secret")
+	assert.NotContains(t, item.Properties, "internal")
+}
@@ -0,0 +1,58 @@
+package payments
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// Fake is an in-memory Adapter for tests and development. Setting "decline" in the client
+// data makes registration fail.
+type Fake struct {
+	mu        sync.Mutex
+	Customers map[string]string
+}
+
+// NewFake returns an empty Fake adapter
+func NewFake() *Fake {
+	return &Fake{Customers: map[string]string{}}
+}
+
+// Name returns the name of the fake processor
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// Register creates an in-memory customer for the user
+func (f *Fake) Register(u *mongodb.User, data map[string]string) (*mongodb.PaymentProcessor, error) {
+	if data["decline"] != "" {
+		return nil, ErrDeclined
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	customer := fmt.Sprintf("fake_%s", uuid.New().String())
+	f.Customers[customer] = u.ID
+
+	return &mongodb.PaymentProcessor{
+		Name:        f.Name(),
+		Customer:    customer,
+		Description: fmt.Sprintf("fake card ending %s", data["last4"]),
+		Created:     time.Now().UTC(),
+	}, nil
+}
+
+// Unregister removes the in-memory customer
+func (f *Fake) Unregister(u *mongodb.User, p *mongodb.PaymentProcessor) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.Customers, p.Customer)
+
+	return nil
+}
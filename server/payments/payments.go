@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+var (
+	// ErrUnknownProcessor is returned when no adapter is registered for a processor name
+	ErrUnknownProcessor = errors.New("unknown payment processor")
+	// ErrDeclined is returned when the processor declines the payment method
+	ErrDeclined = errors.New("payment method declined")
+	// ErrInvalidData is returned when the client data does not describe a payment method the
+	// processor accepts
+	ErrInvalidData = errors.New("invalid payment method")
+)
+
+func init() {
+	apierror.Register(http.StatusBadRequest, ErrUnknownProcessor, ErrInvalidData)
+	apierror.Register(http.StatusPaymentRequired, ErrDeclined)
+}
+
+// Adapter is the contract that every payment processor integration will need to adhere to
+type Adapter interface {
+	// Name returns the identifier the processor is stored under on a user
+	Name() string
+	// Register creates a customer for the user with the processor from client supplied data
+	Register(u *mongodb.User, data map[string]string) (*mongodb.PaymentProcessor, error)
+	// Unregister removes the user's customer from the processor
+	Unregister(u *mongodb.User, p *mongodb.PaymentProcessor) error
+}
+
+// Registry contains the adapters for every supported payment processor
+type Registry struct {
+	adapters map[string]Adapter
+}
+
+// NewRegistry returns a Registry containing the provided adapters
+func NewRegistry(adapters ...Adapter) *Registry {
+	r := &Registry{adapters: map[string]Adapter{}}
+	for _, a := range adapters {
+		r.adapters[a.Name()] = a
+	}
+
+	return r
+}
+
+// Get returns the adapter registered for the processor name
+func (r *Registry) Get(name string) (Adapter, error) {
+	a, ok := r.adapters[name]
+	if !ok {
+		return nil, ErrUnknownProcessor
+	}
+
+	return a, nil
+}
+
+// Names returns the names of all registered processors in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.adapters))
+	for name := range r.adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
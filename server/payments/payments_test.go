@@ -0,0 +1,40 @@
+package payments
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+func TestRegistry(t *testing.T) {
+	fake := NewFake()
+	r := NewRegistry(fake)
+
+	a, err := r.Get("fake")
+	assert.NoError(t, err)
+	assert.Equal(t, fake, a)
+	assert.Equal(t, []string{"fake"}, r.Names())
+
+	_, err = r.Get("stripe")
+	assert.Equal(t, ErrUnknownProcessor, err)
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake()
+	u := mongodb.TestUser(true)
+
+	p, err := fake.Register(u, map[string]string{"last4": "4242"})
+	assert.NoError(t, err)
+	assert.Equal(t, "fake", p.Name)
+	assert.NotEmpty(t, p.Customer)
+	assert.Equal(t, "fake card ending 4242", p.Description)
+	assert.Equal(t, u.ID, fake.Customers[p.Customer])
+
+	assert.NoError(t, fake.Unregister(u, p))
+	assert.Empty(t, fake.Customers)
+
+	_, err = fake.Register(u, map[string]string{"decline": "true"})
+	assert.Equal(t, ErrDeclined, err)
+}
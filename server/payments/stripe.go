@@ -0,0 +1,136 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// DefaultStripeURL is the Stripe API the adapter calls unless Stripe.URL is changed
+const DefaultStripeURL = "https://api.stripe.com"
+
+// Stripe registers users as customers with Stripe. The client supplies a card token created
+// with Stripe's client libraries in the "token" field, so card details never reach the bridge.
+type Stripe struct {
+	key string
+
+	// URL is the base URL of the Stripe API
+	URL string
+	// HTTPClient sends the requests. It defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+}
+
+// NewStripe returns a Stripe adapter authenticated with the secret API key
+func NewStripe(key string) *Stripe {
+	return &Stripe{
+		key:        key,
+		URL:        DefaultStripeURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the name of the Stripe processor
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+// stripeCustomer is the part of a Stripe customer the adapter reads
+type stripeCustomer struct {
+	ID      string `json:"id"`
+	Sources struct {
+		Data []struct {
+			Brand string `json:"brand"`
+			Last4 string `json:"last4"`
+		} `json:"data"`
+	} `json:"sources"`
+}
+
+// stripeError is the body of a failed Stripe response
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Register creates a Stripe customer for the user with the card token in data
+func (s *Stripe) Register(u *mongodb.User, data map[string]string) (*mongodb.PaymentProcessor, error) {
+	if data["token"] == "" {
+		return nil, ErrInvalidData
+	}
+
+	form := url.Values{
+		"email":          {u.ID},
+		"source":         {data["token"]},
+		"metadata[user]": {u.UUID},
+		"expand[]":       {"sources"},
+	}
+
+	var customer stripeCustomer
+	if err := s.call("POST", "/v1/customers", form, &customer); err != nil {
+		return nil, err
+	}
+
+	description := "card"
+	if len(customer.Sources.Data) > 0 {
+		card := customer.Sources.Data[0]
+		description = fmt.Sprintf("%s ending %s", card.Brand, card.Last4)
+	}
+
+	return &mongodb.PaymentProcessor{
+		Name:        s.Name(),
+		Customer:    customer.ID,
+		Description: description,
+		Created:     time.Now().UTC(),
+	}, nil
+}
+
+// Unregister deletes the user's Stripe customer
+func (s *Stripe) Unregister(u *mongodb.User, p *mongodb.PaymentProcessor) error {
+	return s.call("DELETE", "/v1/customers/"+url.PathEscape(p.Customer), nil, nil)
+}
+
+// call sends a request to the Stripe API and decodes a successful response into out
+func (s *Stripe) call(method, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimRight(s.URL, "/")+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.key, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var e stripeError
+		json.NewDecoder(resp.Body).Decode(&e)
+
+		switch {
+		case e.Error.Type == "card_error":
+			return ErrDeclined
+		case e.Error.Code == "resource_missing" && method == "DELETE":
+			return nil
+		case resp.StatusCode == http.StatusBadRequest:
+			return ErrInvalidData
+		}
+
+		return errors.New("stripe: " + resp.Status + ": " + e.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
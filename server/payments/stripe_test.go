@@ -0,0 +1,66 @@
+package payments
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+func TestStripe(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v1/customers":
+			switch r.PostForm.Get("source") {
+			case "tok_visa":
+				w.Write([]byte(`{"id":"cus_123","sources":{"data":[{"brand":"Visa","last4":"4242"}]}}`))
+			case "tok_chargeDeclined":
+				w.WriteHeader(http.StatusPaymentRequired)
+				w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"resource_missing","message":"No such token"}}`))
+			}
+		case "DELETE /v1/customers/cus_123":
+			w.Write([]byte(`{"id":"cus_123","deleted":true}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"type":"api_error","message":"unexpected"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewStripe("sk_test")
+	s.URL = srv.URL
+	u := mongodb.TestUser(true)
+
+	p, err := s.Register(u, map[string]string{"token": "tok_visa"})
+	assert.NoError(t, err)
+	assert.Equal(t, "stripe", p.Name)
+	assert.Equal(t, "cus_123", p.Customer)
+	assert.Equal(t, "Visa ending 4242", p.Description)
+	user, _, _ := got.BasicAuth()
+	assert.Equal(t, "sk_test", user)
+	assert.Equal(t, u.ID, got.PostForm.Get("email"))
+
+	assert.NoError(t, s.Unregister(u, p))
+
+	_, err = s.Register(u, map[string]string{"token": "tok_chargeDeclined"})
+	assert.Equal(t, ErrDeclined, err)
+
+	_, err = s.Register(u, map[string]string{"token": "tok_unknown"})
+	assert.Equal(t, ErrInvalidData, err)
+
+	_, err = s.Register(u, map[string]string{})
+	assert.Equal(t, ErrInvalidData, err)
+
+	err = s.Unregister(u, &mongodb.PaymentProcessor{Customer: "cus_other"})
+	assert.Error(t, err)
+}
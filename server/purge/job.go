@@ -0,0 +1,70 @@
+package purge
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// DefaultGracePeriod is how long a deactivated account is kept before it is purged
+const DefaultGracePeriod = 30 * 24 * time.Hour
+
+// Job permanently deletes accounts whose deactivation was confirmed more than the grace period ago
+type Job struct {
+	db     *mongodb.Client
+	logger log.Logger
+	grace  time.Duration
+}
+
+// NewJob returns a new instance of a configured purge Job
+func NewJob(client *mongodb.Client, logger log.Logger, grace time.Duration) *Job {
+	return &Job{
+		db:     client,
+		logger: logger,
+		grace:  grace,
+	}
+}
+
+// Run purges every user deactivated before now minus the grace period and records an audit
+// entry for each. A failed user is logged and left for the next run.
+func (j *Job) Run(now time.Time) error {
+	users, err := j.db.ListPurgeableUsers(now.Add(-j.grace))
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		removed, err := j.db.PurgeUser(u.ID)
+		if err != nil {
+			level.Error(j.logger).Log("msg", "failed to purge user", "err", err, "user", u.ID)
+			continue
+		}
+
+		if _, err := j.db.CreateAudit(mongodb.AuditUserPurged, u.ID, removed); err != nil {
+			level.Error(j.logger).Log("msg", "failed to record purge audit", "err", err, "user", u.ID)
+		}
+
+		level.Info(j.logger).Log("msg", "purged user", "user", u.ID)
+	}
+
+	return nil
+}
+
+// Schedule runs the purge at start up and then once per interval until ctx is done
+func (j *Job) Schedule(ctx context.Context, interval time.Duration) {
+	for {
+		if err := j.Run(time.Now().UTC()); err != nil {
+			level.Error(j.logger).Log("msg", "purge run failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
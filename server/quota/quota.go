@@ -0,0 +1,92 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+const (
+	// GB is the number of bytes in a gigabyte
+	GB int64 = 1000 * 1000 * 1000
+)
+
+var (
+	// ErrStorageExceeded is returned when a user stores more than their storage allowance
+	ErrStorageExceeded = errors.New("storage quota exceeded")
+	// ErrTransferExceeded is returned when a user has downloaded more than their monthly transfer allowance
+	ErrTransferExceeded = errors.New("monthly transfer quota exceeded")
+)
+
+func init() {
+	apierror.Register(http.StatusPaymentRequired, ErrStorageExceeded, ErrTransferExceeded)
+}
+
+// Limits defines the allowances for a tier in bytes: Storage caps the bytes a user stores at
+// any time and Transfer the bytes they download each month. A zero value means unlimited.
+type Limits struct {
+	Storage  int64 `json:"storage"`
+	Transfer int64 `json:"transfer"`
+}
+
+// Tiers contains the limits applied to free and paid users
+type Tiers struct {
+	Free Limits `json:"free"`
+	Paid Limits `json:"paid"`
+}
+
+// DefaultTiers returns the limits used when none are configured
+func DefaultTiers() Tiers {
+	return Tiers{
+		Free: Limits{
+			Storage:  25 * GB,
+			Transfer: 25 * GB,
+		},
+	}
+}
+
+// For returns the limits that apply to the provided user
+func (t Tiers) For(u *mongodb.User) Limits {
+	if u.IsFreeTier {
+		return t.Free
+	}
+
+	return t.Paid
+}
+
+// StorageCounter reports how many bytes a user stores
+type StorageCounter interface {
+	GetStoredBytes(user string) (int64, error)
+}
+
+// CheckStorage returns ErrStorageExceeded if the user stores more than their storage allowance.
+// Stored bytes are only counted for users with a limit.
+func (t Tiers) CheckStorage(db StorageCounter, u *mongodb.User) error {
+	limit := t.For(u).Storage
+	if limit <= 0 {
+		return nil
+	}
+
+	stored, err := db.GetStoredBytes(u.ID)
+	if err != nil {
+		return err
+	}
+	if stored > limit {
+		return ErrStorageExceeded
+	}
+
+	return nil
+}
+
+// CheckTransfer returns ErrTransferExceeded if the user has used up this month's transfer allowance
+func (t Tiers) CheckTransfer(u *mongodb.User, now time.Time) error {
+	limit := t.For(u).Transfer
+	if limit > 0 && u.BytesDownloaded.Rolled(now).LastMonthBytes >= limit {
+		return ErrTransferExceeded
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Now().UTC()
+	tiers := Tiers{Free: Limits{Storage: 100, Transfer: 200}}
+
+	cases := []struct {
+		name             string
+		user             mongodb.User
+		stored           int64
+		expectedStorage  error
+		expectedTransfer error
+	}{
+		{
+			name: "free user at storage quota",
+			user: mongodb.User{
+				IsFreeTier:      true,
+				BytesDownloaded: mongodb.BytesMeta{LastMonthBytes: 199, LastMonthStarted: now},
+			},
+			stored: 100,
+		},
+		{
+			name: "free user over quota",
+			user: mongodb.User{
+				IsFreeTier:      true,
+				BytesDownloaded: mongodb.BytesMeta{LastMonthBytes: 300, LastMonthStarted: now},
+			},
+			stored:           101,
+			expectedStorage:  ErrStorageExceeded,
+			expectedTransfer: ErrTransferExceeded,
+		},
+		{
+			name: "free user uploaded more than they store",
+			user: mongodb.User{
+				IsFreeTier:      true,
+				BytesUploaded:   mongodb.BytesMeta{LastMonthBytes: 100, LastMonthStarted: now},
+				BytesDownloaded: mongodb.BytesMeta{LastMonthBytes: 300, LastMonthStarted: now.AddDate(0, -2, 0)},
+			},
+			stored: 50,
+		},
+		{
+			name: "paid user is unlimited",
+			user: mongodb.User{
+				BytesDownloaded: mongodb.BytesMeta{LastMonthBytes: 1000, LastMonthStarted: now},
+			},
+			stored: 1000,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expectedStorage, tiers.CheckStorage(storageCounter{bytes: c.stored}, &c.user), c.name)
+		assert.Equal(t, c.expectedTransfer, tiers.CheckTransfer(&c.user, now), c.name)
+	}
+}
+
+func TestCheckStorageError(t *testing.T) {
+	tiers := Tiers{Free: Limits{Storage: 100}}
+	failed := errors.New("unavailable")
+
+	assert.Equal(t, failed, tiers.CheckStorage(storageCounter{err: failed}, &mongodb.User{IsFreeTier: true}))
+	assert.NoError(t, tiers.CheckStorage(storageCounter{err: failed}, &mongodb.User{}), "unlimited users are not counted")
+}
+
+type storageCounter struct {
+	bytes int64
+	err   error
+}
+
+func (s storageCounter) GetStoredBytes(string) (int64, error) {
+	return s.bytes, s.err
+}
@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/routes/apierror"
+)
+
+// Rate allows Limit requests per Per, refilled continuously. A zero Rate is unlimited.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// State is the token bucket for a single key
+type State struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time elapsed since it was last updated and removes a
+// token. It returns the new state, whether a token was available, and how long until one
+// will be if not. A zero State is a full bucket.
+func (r Rate) Take(s State, now time.Time) (State, bool, time.Duration) {
+	perToken := r.Per / time.Duration(r.Limit)
+
+	if s.Updated.IsZero() {
+		s.Tokens = float64(r.Limit)
+	} else if elapsed := now.Sub(s.Updated); elapsed > 0 {
+		s.Tokens = math.Min(float64(r.Limit), s.Tokens+float64(elapsed)/float64(perToken))
+	}
+	s.Updated = now
+
+	if s.Tokens < 1 {
+		wait := time.Duration((1 - s.Tokens) * float64(perToken))
+		return s, false, wait
+	}
+
+	s.Tokens--
+
+	return s, true, 0
+}
+
+// Store holds token buckets by key
+type Store interface {
+	Take(key string, rate Rate, now time.Time) (bool, time.Duration, error)
+}
+
+// maxKeyedBody is the largest body BodyKey reads. Larger bodies are not keyed and fail when
+// the handler reads them.
+const maxKeyedBody = 64 << 10
+
+// KeyFunc returns the account a request is made on behalf of, or "" if there is none
+type KeyFunc func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) string
+
+// Policy limits a route by client IP and by account
+type Policy struct {
+	Name    string
+	IP      Rate
+	Account Rate
+	Key     KeyFunc
+}
+
+// Limiter applies policies to routes using a shared Store
+type Limiter struct {
+	store Store
+	// TrustForwarded uses the last X-Forwarded-For address, the one the proxy in front of the
+	// server appended, as the client IP. Only enable it behind a proxy that sets the header.
+	TrustForwarded bool
+}
+
+// NewLimiter returns a new instance of a configured Limiter
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		store: store,
+	}
+}
+
+// Limit wraps the handler so requests over the policy are rejected with 429 and a
+// Retry-After header. If the store fails the request is let through.
+func (l *Limiter) Limit(p Policy, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		now := time.Now().UTC()
+
+		if p.IP.Limit > 0 {
+			if !l.take(w, r, "ip:"+p.Name+":"+l.clientIP(r), p.IP, now) {
+				return
+			}
+		}
+
+		if p.Account.Limit > 0 && p.Key != nil {
+			if account := p.Key(w, r, ps); account != "" {
+				if !l.take(w, r, "account:"+p.Name+":"+strings.ToLower(account), p.Account, now) {
+					return
+				}
+			}
+		}
+
+		h(w, r, ps)
+	}
+}
+
+func (l *Limiter) take(w http.ResponseWriter, r *http.Request, key string, rate Rate, now time.Time) bool {
+	ok, wait, err := l.store.Take(key, rate, now)
+	if err != nil {
+		level.Error(logging.FromContext(r.Context())).Log("msg", "rate limit store failed", "err", err, "key", key)
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	apierror.Write(w, apierror.New(http.StatusTooManyRequests, "rate limit exceeded"))
+
+	return false
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.TrustForwarded {
+		// earlier entries are whatever the client sent, so only the last can be trusted
+		if fwd := r.Header["X-Forwarded-For"]; len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// BasicAuthKey keys requests by their basic auth username
+func BasicAuthKey(_ http.ResponseWriter, r *http.Request, _ httprouter.Params) string {
+	id, _, _ := r.BasicAuth()
+	return id
+}
+
+// ParamKey keys requests by a route parameter
+func ParamKey(name string) KeyFunc {
+	return func(_ http.ResponseWriter, _ *http.Request, ps httprouter.Params) string {
+		return ps.ByName(name)
+	}
+}
+
+// ResolveKey maps the account returned by key to a canonical ID with resolve, so the
+// different names of an account share a bucket. If resolve fails the original key is used.
+func ResolveKey(key KeyFunc, resolve func(string) (string, error)) KeyFunc {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) string {
+		account := key(w, r, ps)
+		if account == "" {
+			return ""
+		}
+
+		id, err := resolve(account)
+		if err != nil || id == "" {
+			return account
+		}
+
+		return id
+	}
+}
+
+// BodyKey keys requests by a string field of their JSON body. The body is restored so the
+// handler can still read it. Bodies over maxKeyedBody are not keyed, and the handler gets the
+// read error after the bytes that were read.
+func BodyKey(field string) KeyFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) string {
+		if r.Body == nil {
+			return ""
+		}
+
+		body := r.Body
+		b, err := ioutil.ReadAll(http.MaxBytesReader(w, body, maxKeyedBody))
+		if err != nil {
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(b), errReader{err}), body}
+			return ""
+		}
+		body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return ""
+		}
+
+		value, _ := fields[field].(string)
+
+		return value
+	}
+}
+
+// readCloser reads from a replacement reader and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader fails every read with err
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	rate := Rate{Limit: 2, Per: time.Minute}
+	now := time.Now()
+
+	s, ok, _ := rate.Take(State{}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, s.Tokens)
+
+	s, ok, _ = rate.Take(s, now)
+	assert.True(t, ok)
+
+	s, ok, wait := rate.Take(s, now)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+
+	s, ok, _ = rate.Take(s, now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	s, _, _ = rate.Take(s, now.Add(time.Hour))
+	assert.Equal(t, 1.0, s.Tokens, "refill is capped at the limit")
+}
+
+func TestLimit(t *testing.T) {
+	limiter := NewLimiter(NewMemory())
+	policy := Policy{
+		Name:    "test",
+		IP:      Rate{Limit: 3, Per: time.Hour},
+		Account: Rate{Limit: 1, Per: time.Hour},
+		Key:     BodyKey("email"),
+	}
+
+	var received []string
+	handle := limiter.Limit(policy, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		b := make([]byte, 64)
+		n, _ := r.Body.Read(b)
+		received = append(received, string(b[:n]))
+	})
+
+	cases := []struct {
+		name     string
+		remote   string
+		body     string
+		expected int
+	}{
+		{name: "first request", remote: "1.2.3.4:1000", body: `{"email":"a@storj.io"}`, expected: http.StatusOK},
+		{name: "same account", remote: "1.2.3.5:1000", body: `{"email":"A@storj.io"}`, expected: http.StatusTooManyRequests},
+		{name: "other account", remote: "1.2.3.4:1001", body: `{"email":"b@storj.io"}`, expected: http.StatusOK},
+		{name: "no account", remote: "1.2.3.4:1002", body: `{}`, expected: http.StatusOK},
+		{name: "ip exhausted", remote: "1.2.3.4:1003", body: `{"email":"c@storj.io"}`, expected: http.StatusTooManyRequests},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/users", strings.NewReader(c.body))
+		r.RemoteAddr = c.remote
+		w := httptest.NewRecorder()
+
+		handle(w, r, nil)
+
+		assert.Equal(t, c.expected, w.Code, c.name)
+		if c.expected == http.StatusTooManyRequests {
+			assert.NotEmpty(t, w.Header().Get("Retry-After"), c.name)
+		}
+	}
+
+	assert.Equal(t, `{"email":"a@storj.io"}`, received[0], "body is restored for the handler")
+}
+
+func TestBodyKeyLimit(t *testing.T) {
+	body := `{"email":"a@storj.io","padding":"` + strings.Repeat("x", maxKeyedBody) + `"}`
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+
+	assert.Equal(t, "", BodyKey("email")(httptest.NewRecorder(), r, nil))
+
+	_, err := ioutil.ReadAll(r.Body)
+	assert.Error(t, err, "the handler sees the oversized body fail")
+}
+
+func TestResolveKey(t *testing.T) {
+	accounts := map[string]string{"a@storj.io": "id-a", "id-a": "id-a"}
+	key := ResolveKey(ParamKey("id"), func(k string) (string, error) {
+		if id, ok := accounts[k]; ok {
+			return id, nil
+		}
+		return "", errors.New("not found")
+	})
+
+	for param, expected := range map[string]string{"a@storj.io": "id-a", "id-a": "id-a", "other": "other", "": ""} {
+		ps := httprouter.Params{{Key: "id", Value: param}}
+		assert.Equal(t, expected, key(nil, nil, ps), param)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1000"
+	r.Header.Add("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+	r.Header.Add("X-Forwarded-For", "3.3.3.3, 4.4.4.4")
+
+	assert.Equal(t, "10.0.0.1", (&Limiter{}).clientIP(r))
+	assert.Equal(t, "4.4.4.4", (&Limiter{TrustForwarded: true}).clientIP(r), "entries before the proxy's are client controlled")
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// sweepEvery is how many takes the in-memory store allows between sweeps of full buckets
+const sweepEvery = 1024
+
+// Memory keeps token buckets in process. Each instance of the server limits independently.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	takes   int
+}
+
+type memoryBucket struct {
+	state State
+	rate  Rate
+}
+
+// NewMemory returns an empty in-process Store
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]memoryBucket{}}
+}
+
+// Take removes a token from the bucket for the key
+func (m *Memory) Take(key string, rate Rate, now time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.takes++
+	if m.takes%sweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	state, ok, wait := rate.Take(m.buckets[key].state, now)
+	m.buckets[key] = memoryBucket{state: state, rate: rate}
+
+	return ok, wait, nil
+}
+
+// sweep forgets buckets that have refilled, since a missing bucket is a full one
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.state.Updated) >= b.rate.Per {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Mongo keeps token buckets in the database so every instance of the server shares them
+type Mongo struct {
+	db *mongodb.Client
+}
+
+// NewMongo returns a Store backed by the ratelimits collection
+func NewMongo(client *mongodb.Client) (*Mongo, error) {
+	if err := client.EnsureRateLimitIndex(); err != nil {
+		return nil, err
+	}
+
+	return &Mongo{db: client}, nil
+}
+
+// Take removes a token from the bucket for the key. A bucket too contended to update is
+// treated as empty, since contention means the key is being flooded.
+func (m *Mongo) Take(key string, rate Rate, now time.Time) (bool, time.Duration, error) {
+	var (
+		ok   bool
+		wait time.Duration
+	)
+
+	err := m.db.UpdateRateLimit(key, func(tokens float64, updated time.Time) (float64, time.Time, time.Time) {
+		var s State
+		s, ok, wait = rate.Take(State{Tokens: tokens, Updated: updated}, now)
+		return s.Tokens, s.Updated, s.Updated.Add(rate.Per)
+	})
+	if err == mongodb.ErrRateLimitConflict {
+		return false, rate.Per / time.Duration(rate.Limit), nil
+	}
+
+	return ok, wait, err
+}
@@ -0,0 +1,84 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/globalsign/mgo"
+)
+
+// Error is the body of every failed response. Code is stable for clients to branch on,
+// Message is meant for people.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var codes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusPaymentRequired:     "payment_required",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusBadGateway:          "bad_gateway",
+}
+
+// statuses maps known errors to the status they are reported with. Anything else is an
+// internal error and its message is not shown to the client.
+var statuses = map[error]int{
+	mgo.ErrNotFound: http.StatusNotFound,
+}
+
+// Register reports errs with status, showing their messages to clients. Packages register the
+// errors they define from an init function, so every mapping is in place before a request is
+// served.
+func Register(status int, errs ...error) {
+	for _, err := range errs {
+		statuses[err] = status
+	}
+}
+
+// New returns an Error with the code for the status
+func New(status int, message string) *Error {
+	code, ok := codes[status]
+	if !ok {
+		code = "error"
+	}
+
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// From converts err to an Error using the status it is mapped to
+func From(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	if status, ok := statuses[err]; ok {
+		return New(status, err.Error())
+	}
+
+	if mgo.IsDup(err) {
+		return New(http.StatusConflict, "already exists")
+	}
+
+	return New(http.StatusInternalServerError, "internal server error")
+}
+
+// Write writes err as a JSON error response
+func Write(w http.ResponseWriter, err error) {
+	e := From(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+
+	json.NewEncoder(w).Encode(e)
+}
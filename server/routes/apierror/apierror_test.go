@@ -0,0 +1,76 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globalsign/mgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	errInvalid := errors.New("invalid thing")
+	errExists := errors.New("thing already exists")
+	Register(http.StatusBadRequest, errInvalid)
+	Register(http.StatusConflict, errExists)
+
+	cases := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedCode    string
+		expectedMessage string
+	}{
+		{
+			name:            "registered error",
+			err:             errInvalid,
+			expectedStatus:  http.StatusBadRequest,
+			expectedCode:    "bad_request",
+			expectedMessage: errInvalid.Error(),
+		},
+		{
+			name:            "not found",
+			err:             mgo.ErrNotFound,
+			expectedStatus:  http.StatusNotFound,
+			expectedCode:    "not_found",
+			expectedMessage: "not found",
+		},
+		{
+			name:            "registered conflict",
+			err:             errExists,
+			expectedStatus:  http.StatusConflict,
+			expectedCode:    "conflict",
+			expectedMessage: errExists.Error(),
+		},
+		{
+			name:            "explicit error",
+			err:             New(http.StatusTooManyRequests, "slow down"),
+			expectedStatus:  http.StatusTooManyRequests,
+			expectedCode:    "rate_limited",
+			expectedMessage: "slow down",
+		},
+		{
+			name:            "unknown errors are hidden",
+			err:             errors.New("no reachable servers"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedCode:    "internal_error",
+			expectedMessage: "internal server error",
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		Write(w, c.err)
+
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body), c.name)
+
+		assert.Equal(t, c.expectedStatus, w.Code, c.name)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), c.name)
+		assert.Equal(t, c.expectedCode, body["error"], c.name)
+		assert.Equal(t, c.expectedMessage, body["message"], c.name)
+	}
+}
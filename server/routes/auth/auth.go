@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/totp"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+const (
+	// TOTPHeader carries the TOTP or recovery code for users with two-factor enabled
+	TOTPHeader = "X-TOTP-Code"
+
+	// maxTOTPAttempts is how many second factor codes a user may try within totpAttemptWindow
+	maxTOTPAttempts = 5
+	// totpAttemptWindow is how long after the last attempt a user's attempts are forgotten
+	totpAttemptWindow = 15 * time.Minute
+)
+
+var (
+	// ErrUnauthorized is returned when a request carries missing or invalid credentials
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when an authenticated user may not access the requested resource
+	ErrForbidden = errors.New("forbidden")
+	// ErrTOTPRequired is returned when a user with two-factor enabled sends a missing or invalid code
+	ErrTOTPRequired = errors.New("two-factor code required")
+)
+
+func init() {
+	apierror.Register(http.StatusUnauthorized, ErrUnauthorized, ErrTOTPRequired)
+	apierror.Register(http.StatusForbidden, ErrForbidden)
+}
+
+// BasicAuth verifies the basic auth credentials on the request and returns the matching user,
+// who is added to the request logger. Users with two-factor enabled must also send a valid
+// code in the TOTPHeader.
+func BasicAuth(db *mongodb.Client, r *http.Request) (*mongodb.User, error) {
+	id, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	user, err := db.AuthenticateUser(id, password)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	if err := SecondFactor(db, user, r); err != nil {
+		return nil, err
+	}
+
+	logging.SetUser(r.Context(), user.ID)
+
+	return user, nil
+}
+
+// SecondFactor verifies the TOTP or recovery code on the request when the user has two-factor
+// enabled. A matching recovery code is consumed, and a TOTP code is not accepted again. Each
+// code tried counts towards maxTOTPAttempts, after which the user is locked out until
+// totpAttemptWindow has passed without attempts.
+func SecondFactor(db *mongodb.Client, user *mongodb.User, r *http.Request) error {
+	if !user.TOTPEnabled {
+		return nil
+	}
+
+	code := r.Header.Get(TOTPHeader)
+	if code == "" {
+		return ErrTOTPRequired
+	}
+
+	if err := db.TakeTOTPAttempt(user.ID, maxTOTPAttempts, totpAttemptWindow); err != nil {
+		return err
+	}
+
+	if step, ok := totp.Match(user.TOTPSecret, code, time.Now()); ok {
+		err := db.AcceptTOTPStep(user.ID, step)
+		if err == mongodb.ErrTOTPReplayed {
+			return ErrTOTPRequired
+		}
+		return err
+	}
+
+	err := db.UseRecoveryCode(user.ID, code)
+	if err == mongodb.ErrInvalidRecoveryCode {
+		return ErrTOTPRequired
+	}
+
+	return err
+}
+
+// CanAccess reports whether the user may act on resources owned by the provided user ID
+func CanAccess(user *mongodb.User, owner string) bool {
+	return user.IsAdmin || user.ID == owner
+}
+
+// Admin verifies the basic auth credentials on the request belong to an administrator
+func Admin(db *mongodb.Client, r *http.Request) (*mongodb.User, error) {
+	user, err := BasicAuth(db, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsAdmin {
+		return nil, ErrForbidden
+	}
+
+	return user, nil
+}
@@ -1,38 +1,246 @@
 package buckets
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
+	"github.com/globalsign/mgo"
+	"github.com/go-kit/kit/log/level"
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/quota"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/auth"
+	"github.com/coyle/bridge/storage/mongodb"
 )
 
+// TokenRequest contains all fields that will be used in a token request body
+type TokenRequest struct {
+	Operation string `json:"operation"`
+	File      string `json:"file"`
+	Frame     string `json:"frame"`
+}
+
+// Bucket contains all configuration and methods to process bucket requests
+type Bucket struct {
+	db     *mongodb.Client
+	quotas quota.Tiers
+}
+
+// NewServer returns a new instance of a configured Bucket Server
+func NewServer(client *mongodb.Client, quotas quota.Tiers) *Bucket {
+	return &Bucket{
+		db:     client,
+		quotas: quotas,
+	}
+}
+
 // Get retrieves a bucket from the database
-func Get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (b *Bucket) Get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // GetByID retrieves a bucket with the provided ID
-func GetByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (b *Bucket) GetByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // Create initializes a new bucket
-func Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (b *Bucket) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // DestroyByID removes a bucket with the provided ID
-func DestroyByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (b *Bucket) DestroyByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // UpdateByID updates a bucket with the provided ID
-func UpdateByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (b *Bucket) UpdateByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
-// CreateToken initializes a new token for the bucket associated with the provided ID
-func CreateToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	fmt.Fprint(w, "Welcome!\n")
+// CreateToken initializes a new token for the bucket associated with the provided ID.
+// The bytes covered by the token are added to the user's transfer counters before the token is
+// issued, so no token is handed out without being accounted for.
+func (b *Bucket) CreateToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := b.db.WithLogger(logger)
+
+	user, err := auth.BasicAuth(db, r)
+	if err != nil {
+		level.Info(logger).Log("msg", "failed to authenticate user", "err", err, "bucket", ps.ByName("id"))
+		apierror.Write(w, err)
+		return
+	}
+
+	bucket, err := db.GetBucket(ps.ByName("id"))
+	if err == mgo.ErrNotFound {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get bucket", "err", err, "bucket", ps.ByName("id"))
+		apierror.Write(w, err)
+		return
+	}
+
+	if !auth.CanAccess(user, bucket.User) {
+		level.Info(logger).Log("msg", "user does not own bucket", "bucket", bucket.ID)
+		apierror.Write(w, auth.ErrForbidden)
+		return
+	}
+
+	body, err := getTokenBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	if body.Operation == mongodb.OperationPull {
+		err := b.checkTransferQuota(db, user, bucket.User)
+		if err == quota.ErrTransferExceeded {
+			level.Info(logger).Log("msg", "transfer quota exceeded", "owner", bucket.User)
+			apierror.Write(w, err)
+			return
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to check transfer quota", "err", err, "owner", bucket.User)
+			apierror.Write(w, err)
+			return
+		}
+	}
+
+	size, err := b.transferSize(db, bucket, body)
+	if err == mgo.ErrNotFound || err == mongodb.ErrInvalidOperation {
+		apierror.Write(w, err)
+		return
+	}
+	if err == auth.ErrForbidden {
+		level.Info(logger).Log("msg", "frame does not belong to bucket owner", "bucket", bucket.ID, "frame", body.Frame)
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to determine transfer size", "err", err, "bucket", bucket.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	// frames grow as shards are added, so the quota is checked for each push rather than only
+	// when the frame is created
+	if body.Operation == mongodb.OperationPush {
+		err := b.checkStorageQuota(db, user, bucket.User)
+		if err == quota.ErrStorageExceeded {
+			level.Info(logger).Log("msg", "storage quota exceeded", "owner", bucket.User)
+			apierror.Write(w, err)
+			return
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to check storage quota", "err", err, "owner", bucket.User)
+			apierror.Write(w, err)
+			return
+		}
+	}
+
+	if body.Operation == mongodb.OperationPush {
+		_, err = db.RecordBytesUploaded(bucket.User, size)
+	} else {
+		_, err = db.RecordBytesDownloaded(bucket.User, size)
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to record usage", "err", err, "owner", bucket.User, "bytes", size)
+		apierror.Write(w, err)
+		return
+	}
+
+	token, err := db.CreateToken(bucket.ID, body.Operation)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to create token", "err", err, "bucket", bucket.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(token)
+}
+
+// checkTransferQuota verifies the bucket owner may still download this month
+func (b *Bucket) checkTransferQuota(db *mongodb.Client, requester *mongodb.User, owner string) error {
+	user, err := bucketOwner(db, requester, owner)
+	if err != nil {
+		return err
+	}
+
+	return b.quotas.CheckTransfer(user, time.Now().UTC())
+}
+
+// checkStorageQuota verifies the bucket owner stores no more than their allowance
+func (b *Bucket) checkStorageQuota(db *mongodb.Client, requester *mongodb.User, owner string) error {
+	user, err := bucketOwner(db, requester, owner)
+	if err != nil {
+		return err
+	}
+
+	return b.quotas.CheckStorage(db, user)
+}
+
+// bucketOwner returns the user with the owner ID, which is the requester unless an admin is
+// acting on another user's bucket
+func bucketOwner(db *mongodb.Client, requester *mongodb.User, owner string) (*mongodb.User, error) {
+	if requester.ID == owner {
+		return requester, nil
+	}
+
+	return db.GetUser(owner)
+}
+
+// transferSize returns the number of bytes a token will allow to be transferred.
+// Uploads are sized by the frame being pushed, which must belong to the bucket owner, and
+// downloads by the frame behind the file.
+func (b *Bucket) transferSize(db *mongodb.Client, bucket *mongodb.Bucket, body TokenRequest) (int64, error) {
+	frameID := body.Frame
+
+	switch body.Operation {
+	case mongodb.OperationPush:
+	case mongodb.OperationPull:
+		entry, err := db.GetBucketEntry(bucket.ID, body.File)
+		if err != nil {
+			return 0, err
+		}
+		frameID = entry.Frame
+	default:
+		return 0, mongodb.ErrInvalidOperation
+	}
+
+	frame, err := db.GetFrame(frameID)
+	if err != nil {
+		return 0, err
+	}
+
+	if body.Operation == mongodb.OperationPush && frame.User != bucket.User {
+		return 0, auth.ErrForbidden
+	}
+
+	return int64(frame.Size), nil
+}
+
+func getTokenBody(r *http.Request) (TokenRequest, error) {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+
+	tr := TokenRequest{}
+
+	if err := decoder.Decode(&tr); err != nil && err != io.EOF {
+		return tr, err
+	}
+
+	return tr, nil
 }
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/storage"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// The storage packages do not depend on the API, so the statuses of their errors are
+// registered here
+func init() {
+	apierror.Register(http.StatusBadRequest,
+		mongodb.ErrInvalidID,
+		mongodb.ErrInvalidPreferences,
+		mongodb.ErrInvalidOperation,
+		mongodb.ErrInvalidPurpose,
+		mongodb.ErrTOTPNotEnrolled,
+		storage.ErrInvalidPublicKey,
+		storage.ErrInvalidPartner,
+	)
+	apierror.Register(http.StatusUnauthorized,
+		mongodb.ErrInvalidCredentials,
+		mongodb.ErrInvalidRecoveryCode,
+	)
+	apierror.Register(http.StatusNotFound,
+		mongodb.ErrInvalidToken,
+		mongodb.ErrNoPendingEmail,
+		mongodb.ErrProcessorNotFound,
+	)
+	apierror.Register(http.StatusConflict,
+		mongodb.ErrEmailExists,
+		mongodb.ErrProcessorExists,
+		mongodb.ErrTOTPEnabled,
+		storage.ErrPartnerExists,
+	)
+	apierror.Register(http.StatusTooManyRequests,
+		mongodb.ErrTOTPLocked,
+	)
+}
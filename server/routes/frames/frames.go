@@ -1,33 +1,87 @@
 package frames
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/go-kit/kit/log/level"
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/quota"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/auth"
+	"github.com/coyle/bridge/storage/mongodb"
 )
 
+// Frame contains all configuration and methods to process frame requests
+type Frame struct {
+	db     *mongodb.Client
+	quotas quota.Tiers
+}
+
+// NewServer returns a new instance of a configured Frame Server
+func NewServer(client *mongodb.Client, quotas quota.Tiers) *Frame {
+	return &Frame{
+		db:     client,
+		quotas: quotas,
+	}
+}
+
 // Create initializes a new frame
-func Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	fmt.Fprint(w, "Welcome!\n")
+func (f *Frame) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := f.db.WithLogger(logger)
+
+	user, err := auth.BasicAuth(db, r)
+	if err != nil {
+		level.Info(logger).Log("msg", "failed to authenticate user", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	err = f.quotas.CheckStorage(db, user)
+	if err == quota.ErrStorageExceeded {
+		level.Info(logger).Log("msg", "storage quota exceeded")
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to check storage quota", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	frame, err := db.CreateFrame(user.ID)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to create frame", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(frame)
 }
 
 // AddShard adds an additional shard to a frame
-func AddShard(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (f *Frame) AddShard(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // RemoveByID deletes a frame with the provided ID
-func RemoveByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (f *Frame) RemoveByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // Get retrieves all frames
-func Get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (f *Frame) Get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
 // GetByID retrieves a frame with the provided ID
-func GetByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (f *Frame) GetByID(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
@@ -3,11 +3,25 @@ package routes
 import (
 	"github.com/go-kit/kit/log"
 
+	"github.com/coyle/bridge/server/health"
+	"github.com/coyle/bridge/server/metrics"
+	"github.com/coyle/bridge/server/ratelimit"
+	"github.com/coyle/bridge/server/routes/buckets"
+	"github.com/coyle/bridge/server/routes/frames"
+	"github.com/coyle/bridge/server/routes/partners"
+	"github.com/coyle/bridge/server/routes/reports"
 	"github.com/coyle/bridge/server/routes/users"
 )
 
 // Handler contains all route handlers for a service
 type Handler struct {
-	Logger log.Logger
-	User   *users.User
+	Logger  log.Logger
+	User    *users.User
+	Bucket  *buckets.Bucket
+	Frame   *frames.Frame
+	Partner *partners.Partner
+	Report  *reports.Report
+	Limiter *ratelimit.Limiter
+	Health  *health.Health
+	Metrics *metrics.Metrics
 }
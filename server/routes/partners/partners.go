@@ -0,0 +1,239 @@
+package partners
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/go-kit/kit/log/level"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/billing"
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/auth"
+	"github.com/coyle/bridge/storage"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// Request contains all fields that will be used in a partners request body
+type Request struct {
+	Name     *string `json:"name"`
+	RevShare *int    `json:"revShareTotalPercentage"`
+}
+
+// Partner contains all configuration and methods to process partner requests
+type Partner struct {
+	db *mongodb.Client
+}
+
+// NewServer returns a new instance of a configured Partner Server
+func NewServer(client *mongodb.Client) *Partner {
+	return &Partner{
+		db: client,
+	}
+}
+
+// Create a new referral partner
+func (p *Partner) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := p.db.WithLogger(logger)
+
+	if !p.authorize(w, r) {
+		return
+	}
+
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	np := storage.Partner{}
+	body.apply(&np)
+
+	partner, err := db.CreatePartner(np)
+	if err == storage.ErrInvalidPartner || err == storage.ErrPartnerExists {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to create partner", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(partner)
+}
+
+// List all referral partners
+func (p *Partner) List(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := p.db.WithLogger(logger)
+
+	if !p.authorize(w, r) {
+		return
+	}
+
+	partners, err := db.ListPartners()
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to list partners", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(partners)
+}
+
+// Update the name or revenue share of a referral partner
+func (p *Partner) Update(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := p.db.WithLogger(logger)
+
+	if !p.authorize(w, r) {
+		return
+	}
+
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	partner, ok := p.getPartner(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	body.apply(partner)
+
+	err = db.UpdatePartner(*partner)
+	if err == storage.ErrInvalidPartner || err == storage.ErrPartnerExists {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to update partner", "err", err, "partner", partner.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(partner)
+}
+
+// Report what every user referred by a partner was billed for a calendar month, given as
+// period=YYYY-MM and defaulting to the previous month, and the partner's share of it
+func (p *Partner) Report(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := p.db.WithLogger(logger)
+
+	if !p.authorize(w, r) {
+		return
+	}
+
+	partner, ok := p.getPartner(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	current, _ := billing.Period(now)
+	period := current.AddDate(0, -1, 0)
+	if v := r.URL.Query().Get("period"); v != "" {
+		t, err := time.Parse(periodFormat, v)
+		if err != nil {
+			apierror.Write(w, apierror.New(http.StatusBadRequest, "period must be a month formatted as YYYY-MM"))
+			return
+		}
+		period = t
+	}
+	start, end := billing.Period(period)
+
+	users, err := db.ListReferredUsers(partner.ID)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to list referred users", "err", err, "partner", partner.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+
+	debits, err := db.ListPeriodDebits(ids, start)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to list debits", "err", err, "partner", partner.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(NewReport(partner, users, debits, start, end, now))
+}
+
+// authorize writes the appropriate error and returns false unless the request is from an administrator
+func (p *Partner) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := auth.Admin(p.db.WithLogger(logging.FromContext(r.Context())), r); err != nil {
+		apierror.Write(w, err)
+		return false
+	}
+
+	return true
+}
+
+func (p *Partner) getPartner(w http.ResponseWriter, r *http.Request, id string) (*storage.Partner, bool) {
+	logger := logging.FromContext(r.Context())
+
+	partner, err := p.db.WithLogger(logger).GetPartnerByID(id)
+	if err == mgo.ErrNotFound {
+		apierror.Write(w, err)
+		return nil, false
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get partner", "err", err, "partner", id)
+		apierror.Write(w, err)
+		return nil, false
+	}
+
+	return partner, true
+}
+
+// apply copies the fields present in the request onto the partner
+func (req Request) apply(p *storage.Partner) {
+	if req.Name != nil {
+		p.Name = *req.Name
+	}
+
+	if req.RevShare != nil {
+		p.RevShare = *req.RevShare
+	}
+}
+
+func getBody(r *http.Request) (Request, error) {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+
+	pr := Request{}
+
+	if err := decoder.Decode(&pr); err != nil && err != io.EOF {
+		return pr, err
+	}
+
+	return pr, nil
+}
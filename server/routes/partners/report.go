@@ -0,0 +1,59 @@
+package partners
+
+import (
+	"time"
+
+	"github.com/coyle/bridge/storage"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// periodFormat is how a billing period is named in the report's period query parameter
+const periodFormat = "2006-01"
+
+// Report summarizes what a partner's referred users were billed for one calendar month.
+// Amounts are in cents.
+type Report struct {
+	Partner        storage.Partner `json:"partner"`
+	PeriodStart    time.Time       `json:"periodStart"`
+	PeriodEnd      time.Time       `json:"periodEnd"`
+	Generated      time.Time       `json:"generated"`
+	Users          []ReferredUser  `json:"users"`
+	BilledAmount   int64           `json:"billedAmount"`
+	RevShareAmount int64           `json:"revShareAmount"`
+}
+
+// ReferredUser contains the amount a single referred user was billed for the period
+type ReferredUser struct {
+	UUID         string `json:"uuid"`
+	IsFreeTier   bool   `json:"isFreeTier"`
+	BilledAmount int64  `json:"billedAmount"`
+}
+
+// NewReport totals the debits issued to the referred users for the period starting at start.
+// The debits are fixed once the period is billed, so the report does not change when it is
+// generated again.
+func NewReport(p *storage.Partner, users []mongodb.User, debits []mongodb.Debit, start, end, now time.Time) *Report {
+	report := &Report{
+		Partner:     *p,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Generated:   now,
+		Users:       make([]ReferredUser, 0, len(users)),
+	}
+
+	billed := map[string]int64{}
+	for _, d := range debits {
+		billed[d.User] += d.Amount
+	}
+
+	for _, u := range users {
+		ru := ReferredUser{UUID: u.UUID, IsFreeTier: u.IsFreeTier, BilledAmount: billed[u.ID]}
+
+		report.Users = append(report.Users, ru)
+		report.BilledAmount += ru.BilledAmount
+	}
+
+	report.RevShareAmount = report.BilledAmount * int64(p.RevShare) / 100
+
+	return report
+}
@@ -0,0 +1,39 @@
+package partners
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+func TestNewReport(t *testing.T) {
+	start := time.Date(2018, time.May, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	partner := &storage.Partner{ID: "partner", Name: "CITIZEN", RevShare: 25}
+
+	users := []mongodb.User{
+		{ID: "paid@storj.io", UUID: "paid"},
+		{ID: "idle@storj.io", UUID: "idle"},
+		{ID: "free@storj.io", UUID: "free", IsFreeTier: true},
+	}
+	debits := []mongodb.Debit{
+		{User: "paid@storj.io", Type: mongodb.DebitStorage, Amount: 300},
+		{User: "paid@storj.io", Type: mongodb.DebitBandwidth, Amount: 101},
+	}
+
+	report := NewReport(partner, users, debits, start, end, end.Add(time.Hour))
+
+	assert.Equal(t, start, report.PeriodStart)
+	assert.Equal(t, end, report.PeriodEnd)
+	assert.Len(t, report.Users, 3)
+	assert.Equal(t, int64(401), report.Users[0].BilledAmount)
+	assert.Equal(t, int64(0), report.Users[1].BilledAmount)
+	assert.Equal(t, int64(0), report.Users[2].BilledAmount)
+	assert.True(t, report.Users[2].IsFreeTier)
+	assert.Equal(t, int64(401), report.BilledAmount)
+	assert.Equal(t, int64(100), report.RevShareAmount)
+}
@@ -1,13 +1,66 @@
 package reports
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
 
+	"github.com/go-kit/kit/log/level"
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/auth"
+	"github.com/coyle/bridge/storage/mongodb"
 )
 
-// Create a new exchange report
-func Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	fmt.Fprint(w, "Welcome!\n")
+// Report contains all configuration and methods to process exchange report requests
+type Report struct {
+	db *mongodb.Client
+}
+
+// NewServer returns a new instance of a configured Report Server
+func NewServer(client *mongodb.Client) *Report {
+	return &Report{
+		db: client,
+	}
+}
+
+// Create a new exchange report. The authenticated user is recorded as the client.
+func (rp *Report) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := rp.db.WithLogger(logger)
+
+	user, err := auth.BasicAuth(db, r)
+	if err != nil {
+		level.Info(logger).Log("msg", "failed to authenticate user", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	defer r.Body.Close()
+	body := mongodb.ExchangeReport{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	if body.DataHash == "" || body.ExchangeEnd.Before(body.ExchangeStart) {
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "dataHash is required and exchangeEnd may not be before exchangeStart"))
+		return
+	}
+
+	body.ClientID = user.ID
+
+	report, err := db.CreateExchangeReport(body)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to create exchange report", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(report)
 }
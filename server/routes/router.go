@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"github.com/julienschmidt/httprouter"
+)
+
+// Middleware wraps the handle registered for a method and route pattern
+type Middleware func(method, route string, h httprouter.Handle) httprouter.Handle
+
+// Router registers handles on an httprouter.Router, wrapping each in its middleware first
+type Router struct {
+	*httprouter.Router
+	middleware []Middleware
+}
+
+// NewRouter returns a Router that wraps handles in the middleware before registering them on
+// r. The first middleware is the outermost.
+func NewRouter(r *httprouter.Router, middleware ...Middleware) *Router {
+	return &Router{Router: r, middleware: middleware}
+}
+
+// Handle registers the wrapped handle for the method and path
+func (r *Router) Handle(method, path string, h httprouter.Handle) {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](method, path, h)
+	}
+
+	r.Router.Handle(method, path, h)
+}
+
+// GET registers a wrapped GET handle
+func (r *Router) GET(path string, h httprouter.Handle) { r.Handle("GET", path, h) }
+
+// POST registers a wrapped POST handle
+func (r *Router) POST(path string, h httprouter.Handle) { r.Handle("POST", path, h) }
+
+// PUT registers a wrapped PUT handle
+func (r *Router) PUT(path string, h httprouter.Handle) { r.Handle("PUT", path, h) }
+
+// PATCH registers a wrapped PATCH handle
+func (r *Router) PATCH(path string, h httprouter.Handle) { r.Handle("PATCH", path, h) }
+
+// DELETE registers a wrapped DELETE handle
+func (r *Router) DELETE(path string, h httprouter.Handle) { r.Handle("DELETE", path, h) }
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/coyle/bridge/server/billing"
+	"github.com/coyle/bridge/server/health"
+	"github.com/coyle/bridge/server/openapi"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/buckets"
+	"github.com/coyle/bridge/server/routes/partners"
+	"github.com/coyle/bridge/server/routes/users"
+	"github.com/coyle/bridge/storage"
+	"github.com/coyle/bridge/storage/mongodb"
+)
+
+// Spec returns the OpenAPI document describing every route. A route added to the router must
+// be added here too.
+func Spec() *openapi.Document {
+	info := openapi.Info{
+		Title:       "Storj Bridge",
+		Description: "Manages users, buckets, and files stored on the Storj network.",
+		Version:     "1.0.0",
+	}
+
+	return openapi.New(info, apierror.Error{}, spec)
+}
+
+var exportFormat = openapi.Parameter{
+	Name:        "format",
+	In:          "query",
+	Description: "json, the default, or zip for one JSON file per collection in a zip archive",
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+var reportPeriod = openapi.Parameter{
+	Name:        "period",
+	In:          "query",
+	Description: "The calendar month to report on as YYYY-MM, by default the previous month",
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+// spec documents each route in the order start registers them
+var spec = []openapi.Route{
+	// Buckets
+	{Method: "GET", Path: "/buckets", Tag: "buckets", Summary: "List buckets", Stub: true, Auth: true, Status: http.StatusOK, Response: []mongodb.Bucket{}},
+	{Method: "GET", Path: "/buckets/:id", Tag: "buckets", Summary: "Get a bucket", Stub: true, Auth: true, Status: http.StatusOK, Response: mongodb.Bucket{}},
+	{Method: "GET", Path: "/bucket-ids/:name", Tag: "buckets", Summary: "Get a bucket by name", Stub: true, Auth: true, Status: http.StatusOK, Response: mongodb.Bucket{}},
+	{Method: "POST", Path: "/buckets", Tag: "buckets", Summary: "Create a bucket", Stub: true, Auth: true, Status: http.StatusCreated, Response: mongodb.Bucket{}},
+	{Method: "DELETE", Path: "/buckets/:id", Tag: "buckets", Summary: "Delete a bucket", Stub: true, Auth: true, Status: http.StatusNoContent},
+	{Method: "PATCH", Path: "/buckets/:id", Tag: "buckets", Summary: "Update a bucket", Stub: true, Auth: true, Status: http.StatusOK, Response: mongodb.Bucket{}},
+	{Method: "POST", Path: "/buckets/:id/tokens", Tag: "buckets", Summary: "Create a push or pull token and count its bytes against the owner's transfer", Auth: true, Body: buckets.TokenRequest{}, Status: http.StatusCreated, Response: mongodb.Token{}},
+	// Files
+	{Method: "GET", Path: "/buckets/:id/files", Tag: "files", Summary: "List the files in a bucket", Stub: true, Auth: true, Status: http.StatusOK, Response: []mongodb.BucketEntry{}},
+	{Method: "GET", Path: "/buckets/:id/file-ids/:name", Tag: "files", Summary: "Get the ID of a file by name", Stub: true, Auth: true, Status: http.StatusOK},
+	{Method: "GET", Path: "/buckets/:id/files/:file", Tag: "files", Summary: "Get the pointers to retrieve a file", Stub: true, Auth: true, Status: http.StatusOK},
+	{Method: "DELETE", Path: "/buckets/:id/files/:file", Tag: "files", Summary: "Delete a file", Stub: true, Auth: true, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/buckets/:id/files/:file/info", Tag: "files", Summary: "Get a file's metadata", Stub: true, Auth: true, Status: http.StatusOK, Response: mongodb.BucketEntry{}},
+	{Method: "POST", Path: "/buckets/:id/files", Tag: "files", Summary: "Create a file from a frame", Stub: true, Auth: true, Status: http.StatusCreated, Response: mongodb.BucketEntry{}},
+	{Method: "GET", Path: "/buckets/:id/files/:file/mirrors", Tag: "files", Summary: "List the mirrors of a file's shards", Stub: true, Auth: true, Status: http.StatusOK},
+	// Contacts
+	{Method: "GET", Path: "/contacts", Tag: "contacts", Summary: "List contacts", Stub: true, Status: http.StatusOK, Response: []mongodb.Contact{}},
+	{Method: "GET", Path: "/contacts/:nodeID", Tag: "contacts", Summary: "Get a contact", Stub: true, Status: http.StatusOK, Response: mongodb.Contact{}},
+	{Method: "PATCH", Path: "/contacts/:nodeID", Tag: "contacts", Summary: "Update a contact", Stub: true, Status: http.StatusOK, Response: mongodb.Contact{}},
+	{Method: "POST", Path: "/contacts", Tag: "contacts", Summary: "Register a contact", Stub: true, Body: mongodb.Contact{}, Status: http.StatusCreated, Response: mongodb.Contact{}},
+	{Method: "POST", Path: "/contacts/challenges", Tag: "contacts", Summary: "Create a proof of work challenge", Stub: true, Status: http.StatusCreated},
+	// Frames
+	{Method: "POST", Path: "/frames", Tag: "frames", Summary: "Create a frame", Auth: true, Status: http.StatusOK, Response: mongodb.Frame{}},
+	{Method: "PUT", Path: "/frames/:frame", Tag: "frames", Summary: "Add a shard to a frame", Stub: true, Auth: true, Status: http.StatusOK},
+	{Method: "DELETE", Path: "/frames/:frame", Tag: "frames", Summary: "Delete a frame", Stub: true, Auth: true, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/frames", Tag: "frames", Summary: "List frames", Stub: true, Auth: true, Status: http.StatusOK, Response: []mongodb.Frame{}},
+	{Method: "GET", Path: "/frames/:frame", Tag: "frames", Summary: "Get a frame", Stub: true, Auth: true, Status: http.StatusOK, Response: mongodb.Frame{}},
+	// Public keys
+	{Method: "GET", Path: "/keys", Tag: "keys", Summary: "List public keys", Stub: true, Auth: true, Status: http.StatusOK, Response: []mongodb.PublicKey{}},
+	{Method: "POST", Path: "/keys", Tag: "keys", Summary: "Add a public key", Stub: true, Auth: true, Status: http.StatusCreated, Response: mongodb.PublicKey{}},
+	{Method: "DELETE", Path: "/keys/:pubkey", Tag: "keys", Summary: "Remove a public key", Stub: true, Auth: true, Status: http.StatusNoContent},
+	// Reports
+	{Method: "POST", Path: "/reports/exchanges", Tag: "reports", Summary: "Report a shard exchange", Auth: true, Body: mongodb.ExchangeReport{}, Status: http.StatusCreated, Response: mongodb.ExchangeReport{}},
+	// Partners, administrators only
+	{Method: "GET", Path: "/partners", Tag: "partners", Summary: "List referral partners", Auth: true, Status: http.StatusOK, Response: []storage.Partner{}},
+	{Method: "POST", Path: "/partners", Tag: "partners", Summary: "Create a referral partner", Auth: true, Body: partners.Request{}, Status: http.StatusCreated, Response: storage.Partner{}},
+	{Method: "PATCH", Path: "/partners/:id", Tag: "partners", Summary: "Update a referral partner", Auth: true, Body: partners.Request{}, Status: http.StatusOK, Response: storage.Partner{}},
+	{Method: "GET", Path: "/partners/:id/report", Tag: "partners", Summary: "Report what a partner's referred users were billed for a month", Auth: true, Query: []openapi.Parameter{reportPeriod}, Status: http.StatusOK, Response: partners.Report{}},
+	// Users
+	{Method: "POST", Path: "/users", Tag: "users", Summary: "Register a user and email an activation link", Body: users.Request{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/activations", Tag: "users", Summary: "Resend the activation email if the address belongs to an inactive user", Body: users.Request{}, Status: http.StatusOK},
+	{Method: "GET", Path: "/activations/:token", Tag: "users", Summary: "Activate a user", Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "DELETE", Path: "/users/:id", Tag: "users", Summary: "Email a link to deactivate the user", Auth: true, Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "GET", Path: "/deactivations/:token", Tag: "users", Summary: "Page for confirming deactivation, linked from the deactivation email", Status: http.StatusOK, ContentType: "text/html"},
+	{Method: "POST", Path: "/deactivations/:token", Tag: "users", Summary: "Deactivate a user", Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "PATCH", Path: "/users/:id", Tag: "users", Summary: "Update preferences or request an email change", Auth: true, Body: users.Request{}, Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "GET", Path: "/emails/:token", Tag: "users", Summary: "Confirm an email change", Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "POST", Path: "/resets", Tag: "users", Summary: "Email a password reset link if the address is registered", Body: users.Request{}, Status: http.StatusOK},
+	{Method: "GET", Path: "/resets/:token", Tag: "users", Summary: "Form for choosing a new password, linked from the reset email", Status: http.StatusOK, ContentType: "text/html"},
+	{Method: "POST", Path: "/resets/:token", Tag: "users", Summary: "Reset a password, with X-TOTP-Code if two-factor is enabled", Body: users.Request{}, Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "GET", Path: "/users/:id/usage", Tag: "users", Summary: "Get upload and download counters", Auth: true, Status: http.StatusOK, Response: mongodb.Usage{}},
+	{Method: "GET", Path: "/users/:id/invoices", Tag: "users", Summary: "List invoices", Auth: true, Status: http.StatusOK, Response: []billing.Invoice{}},
+	{Method: "GET", Path: "/users/:id/export", Tag: "users", Summary: "Export everything stored about the user", Auth: true, Query: []openapi.Parameter{exportFormat}, Status: http.StatusOK, Response: mongodb.UserExport{}},
+	{Method: "POST", Path: "/users/:id/totp", Tag: "users", Summary: "Generate a TOTP secret", Auth: true, Status: http.StatusCreated, Response: map[string]string{}},
+	{Method: "POST", Path: "/users/:id/totp/verify", Tag: "users", Summary: "Enable two-factor and return recovery codes", Auth: true, Body: users.Request{}, Status: http.StatusOK, Response: map[string][]string{}},
+	{Method: "DELETE", Path: "/users/:id/totp", Tag: "users", Summary: "Disable two-factor", Auth: true, Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "GET", Path: "/users/:id/preferences", Tag: "users", Summary: "Get preferences", Auth: true, Status: http.StatusOK, Response: mongodb.Preferences{}},
+	{Method: "PATCH", Path: "/users/:id/preferences", Tag: "users", Summary: "Update the preferences present in the body", Auth: true, Body: mongodb.PreferencesUpdate{}, Status: http.StatusOK, Response: mongodb.Preferences{}},
+	{Method: "POST", Path: "/users/:id/payment-processors", Tag: "users", Summary: "Register with a payment processor", Auth: true, Body: users.Request{}, Status: http.StatusCreated, Response: mongodb.UserView{}},
+	{Method: "DELETE", Path: "/users/:id/payment-processors/:processor", Tag: "users", Summary: "Remove a payment processor", Auth: true, Status: http.StatusOK, Response: mongodb.UserView{}},
+	{Method: "PUT", Path: "/users/:id/payment-processors/:processor/default", Tag: "users", Summary: "Make a payment processor the default", Auth: true, Status: http.StatusOK, Response: mongodb.UserView{}},
+	// Operations
+	{Method: "GET", Path: "/health", Tag: "operations", Summary: "Liveness probe", Status: http.StatusOK, Response: map[string]string{}},
+	{Method: "GET", Path: "/ready", Tag: "operations", Summary: "Readiness probe, 503 when a critical dependency is down", Status: http.StatusOK, Response: health.Report{}},
+	{Method: "GET", Path: "/openapi.json", Tag: "operations", Summary: "This document", Status: http.StatusOK, Response: map[string]interface{}{}},
+}
@@ -0,0 +1,57 @@
+package users
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// deactivationForm asks a user who followed the emailed deactivation link to confirm. Opening
+// the link changes nothing, so mail scanners and link previews can not delete the account;
+// only the button posts to the same path.
+var deactivationForm = template.Must(template.New("deactivation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Delete your account</title>
+</head>
+<body>
+<h1>Delete your account</h1>
+<p>Your account and everything stored with it will be deleted once the grace period has passed.</p>
+<form id="deactivate">
+<p><button type="submit">Delete my account</button></p>
+</form>
+<p id="result"></p>
+<script>
+var token = {{.}};
+document.getElementById("deactivate").addEventListener("submit", function (e) {
+	e.preventDefault();
+	var result = document.getElementById("result");
+	fetch("/deactivations/" + encodeURIComponent(token), {method: "POST"}).then(function (resp) {
+		if (resp.ok) {
+			result.textContent = "Your account has been deactivated.";
+			document.getElementById("deactivate").hidden = true;
+			return;
+		}
+		return resp.json().then(function (body) {
+			result.textContent = body.message || "The account could not be deactivated.";
+		});
+	}).catch(function () {
+		result.textContent = "The account could not be deactivated.";
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+// DeactivationForm serves the page the deactivation email links to. The token is only checked
+// when the deactivation is confirmed.
+func (u *User) DeactivationForm(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+
+	deactivationForm.Execute(w, ps.ByName("token"))
+}
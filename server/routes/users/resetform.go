@@ -0,0 +1,78 @@
+package users
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// resetForm lets a user who followed the emailed reset link choose a new password. The
+// password is hashed in the browser, as every client does, and posted to the same path.
+var resetForm = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reset your password</title>
+</head>
+<body>
+<h1>Reset your password</h1>
+<form id="reset">
+<p><label>New password <input type="password" id="password" required autofocus></label></p>
+<p><label>Confirm password <input type="password" id="confirm" required></label></p>
+<p><label>Two-factor code, if enabled <input type="text" id="code" autocomplete="one-time-code"></label></p>
+<p><button type="submit">Reset password</button></p>
+</form>
+<p id="result"></p>
+<script>
+var token = {{.}};
+document.getElementById("reset").addEventListener("submit", function (e) {
+	e.preventDefault();
+	var result = document.getElementById("result");
+	var password = document.getElementById("password").value;
+	if (password !== document.getElementById("confirm").value) {
+		result.textContent = "The passwords do not match.";
+		return;
+	}
+
+	crypto.subtle.digest("SHA-256", new TextEncoder().encode(password)).then(function (sum) {
+		var hex = Array.prototype.map.call(new Uint8Array(sum), function (b) {
+			return ("0" + b.toString(16)).slice(-2);
+		}).join("");
+		var headers = {"Content-Type": "application/json"};
+		var code = document.getElementById("code").value;
+		if (code) {
+			headers["X-TOTP-Code"] = code;
+		}
+		return fetch("/resets/" + encodeURIComponent(token), {
+			method: "POST",
+			headers: headers,
+			body: JSON.stringify({password: hex})
+		});
+	}).then(function (resp) {
+		if (resp.ok) {
+			result.textContent = "Your password has been reset.";
+			document.getElementById("reset").hidden = true;
+			return;
+		}
+		return resp.json().then(function (body) {
+			result.textContent = body.message || "The password could not be reset.";
+		});
+	}).catch(function () {
+		result.textContent = "The password could not be reset.";
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+// ResetForm serves the page the password reset email links to. The token is only checked when
+// the form is submitted.
+func (u *User) ResetForm(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+
+	resetForm.Execute(w, ps.ByName("token"))
+}
@@ -1,87 +1,118 @@
 package users
 
 import (
+	"archive/zip"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/coyle/bridge/server/billing"
+	"github.com/coyle/bridge/server/logging"
+	"github.com/coyle/bridge/server/mailer"
+	"github.com/coyle/bridge/server/payments"
+	"github.com/coyle/bridge/server/routes/apierror"
+	"github.com/coyle/bridge/server/routes/auth"
+	"github.com/coyle/bridge/server/totp"
 	"github.com/coyle/bridge/storage/mongodb"
+	"github.com/globalsign/mgo"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 )
 
 // Request contains all fields that will be used in a users request body
 type Request struct {
-	ReferralPartner string `json:"referralPartner"`
-	Email           string `json:"email"`
-	Password        string `json:"password"`
-	PublicKey       string `json:"pubkey"`
-	Token           string `json:"token"`
+	ReferralPartner string                     `json:"referralPartner"`
+	Email           string                     `json:"email"`
+	Password        string                     `json:"password"`
+	PublicKey       string                     `json:"pubkey"`
+	Token           string                     `json:"token"`
+	Processor       string                     `json:"processor"`
+	Data            map[string]string          `json:"data"`
+	Preferences     *mongodb.PreferencesUpdate `json:"preferences"`
+	Code            string                     `json:"code"`
 }
 
+// recoveryCodeCount is the number of recovery codes issued when two-factor is enabled
+const recoveryCodeCount = 10
+
+// totpIssuer names the service in authenticator apps
+const totpIssuer = "Storj Bridge"
+
 // User contains all configuration and methods to process user requests
 type User struct {
-	db     *mongodb.Client
-	logger log.Logger
+	db         *mongodb.Client
+	mailer     mailer.Mailer
+	templates  *mailer.Templates
+	processors *payments.Registry
 }
 
 // NewServer returns a new instance of a configured User Server
-func NewServer(client *mongodb.Client, logger log.Logger) *User {
+func NewServer(client *mongodb.Client, m mailer.Mailer, t *mailer.Templates, p *payments.Registry) *User {
 	// start
 	return &User{
-		db:     client,
-		logger: logger,
+		db:         client,
+		mailer:     m,
+		templates:  t,
+		processors: p,
 	}
 }
 
 // Create a new user
 func (u *User) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
 	body, err := getBody(r)
 	if err != nil {
-		u.logger.Log("Error getting body", err)
-		w.WriteHeader(http.StatusBadRequest)
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
 		return
 	}
 
 	if body.PublicKey == "" {
-		u.logger.Log("No PublicKey provided", err)
-		w.WriteHeader(http.StatusBadRequest)
+		level.Info(logger).Log("msg", "no public key provided")
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "pubkey is required"))
 		return
 	}
 
 	nuser := mongodb.User{
-		ID:       body.Email,
+		Email:    body.Email,
 		Hashpass: body.Password,
 	}
 
 	if body.ReferralPartner != "" {
-		p, err := u.db.GetPartner(body.ReferralPartner)
-		if err != nil {
-			u.logger.Log("Error getting partner", err)
+		p, err := db.GetPartner(body.ReferralPartner)
+		if err != nil && err != mgo.ErrNotFound {
+			level.Error(logger).Log("msg", "failed to get referral partner", "err", err, "partner", body.ReferralPartner)
+			apierror.Write(w, err)
+			return
 		}
 
-		nuser.ReferralPartner = p.ID
+		if err == nil {
+			nuser.ReferralPartner = p.ID
+		}
 	}
 
 	// do all concurrently ?
-	user, err := u.db.CreateUser(nuser)
-	if err != nil && err == mongodb.ErrInvalidID {
-		u.logger.Log("Error creating user", err)
-		w.WriteHeader(http.StatusBadRequest)
+	user, err := db.CreateUser(nuser)
+	if err == mongodb.ErrInvalidID || err == mongodb.ErrEmailExists {
+		apierror.Write(w, err)
 		return
 	}
 	if err != nil {
-		u.logger.Log("Error creating user", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to create user", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
-	// TODO(coyle): implement Email service
-	defer u.dispatchActivationEmailSwitch(user)
+	go u.dispatchActivationEmailSwitch(logger, user)
 
-	if err := u.db.CreatePublicKey(&user, body.PublicKey); err != nil {
-		u.logger.Log("Error creating public key", err)
+	if err := db.CreatePublicKey(&user, body.PublicKey); err != nil {
+		level.Error(logger).Log("msg", "failed to create public key", "err", err, "account", user.ID)
 		// TODO(coyle): Should we cancel the request and remove the created user or just log?
 		// looks like the node code currently removes the created user
 	}
@@ -90,48 +121,62 @@ func (u *User) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Param
 	return
 }
 
-// Reactivate a user
+// Reactivate emails a new activation link to the inactive user with the email address in the
+// request body. Like CreatePasswordResetToken it answers the same for unknown and already
+// activated addresses, so the route can not be used to find out which are registered.
 func (u *User) Reactivate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
 	body, err := getBody(r)
 	if err != nil {
-		u.logger.Log("invalid request body", err)
-		w.WriteHeader(http.StatusBadRequest)
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
 		return
 	}
 
-	user, err := u.db.GetUser(body.Email)
+	user, err := db.GetUserByEmail(body.Email)
+	if err == mgo.ErrNotFound {
+		level.Info(logger).Log("msg", "activation requested for unknown email")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	if err != nil {
-		// TODO(coyle): handle error
+		level.Error(logger).Log("msg", "failed to get user", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
 	if user.Activated {
-		w.WriteHeader(http.StatusBadRequest)
+		level.Info(logger).Log("msg", "activation requested for active user", "account", user.ID)
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// TODO(coyle): implement Email service
-	go u.dispatchActivationEmailSwitch(*user)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-
-	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+	go u.dispatchActivationEmailSwitch(logger, *user)
 
+	w.WriteHeader(http.StatusOK)
 }
 
 // ConfirmActivation of a user
 func (u *User) ConfirmActivation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	user, err := u.db.GetUserByToken("activator", ps.ByName("token"))
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, err := db.ConsumeUserToken(mongodb.PurposeActivation, ps.ByName("token"))
+	if err == mongodb.ErrInvalidToken {
+		apierror.Write(w, err)
+		return
+	}
 	if err != nil {
-		u.logger.Log("Failed to Get user with provided token", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to get user by activation token", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
-	if err := u.db.ActivateUser(user.ID); err != nil {
-		u.logger.Log("Failed to activate user", err, "ID", user.ID)
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := db.ActivateUser(user.ID); err != nil {
+		level.Error(logger).Log("msg", "failed to activate user", "err", err, "account", user.ID)
+		apierror.Write(w, err)
 		return
 	}
 
@@ -144,31 +189,218 @@ func (u *User) ConfirmActivation(w http.ResponseWriter, r *http.Request, ps http
 
 // Remove a user
 func (u *User) Remove(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	user, err := u.db.GetUser(ps.ByName("id"))
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	token, err := db.IssueUserToken(user.ID, mongodb.PurposeDeactivation)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to issue deactivation token", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	go u.dispatch(logger, u.templates.Deactivation, user.Email, token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+
+}
+
+// ConfirmDeactivation of a user, posted from the page served by DeactivationForm
+func (u *User) ConfirmDeactivation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, err := db.ConsumeUserToken(mongodb.PurposeDeactivation, ps.ByName("token"))
+	if err == mongodb.ErrInvalidToken {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get user by deactivation token", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	if err := db.ConfirmUserDeactivation(user.ID); err != nil {
+		level.Error(logger).Log("msg", "failed to confirm deactivation", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+}
+
+// CreatePasswordResetToken emails a reset link to the user with the email address in the
+// request body. It answers the same whether or not the address belongs to a user, so the
+// route can not be used to find out which addresses are registered.
+func (u *User) CreatePasswordResetToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	user, err := db.GetUserByEmail(body.Email)
+	if err == mgo.ErrNotFound {
+		level.Info(logger).Log("msg", "password reset requested for unknown email")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	if err != nil {
-		u.logger.Log("failed to get user", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to get user", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
-	userID, _, ok := r.BasicAuth()
+	token, err := db.IssueUserToken(user.ID, mongodb.PurposeReset)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to issue password reset token", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	go u.dispatch(logger, u.templates.PasswordReset, user.Email, token)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ConfirmPasswordReset for a user. The token is only consumed once the new password and any
+// second factor have been checked, so a rejected attempt leaves the emailed link working.
+func (u *User) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	if body.Password == "" {
+		level.Info(logger).Log("msg", "no password provided")
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "password is required"))
+		return
+	}
+
+	user, err := db.PeekUserToken(mongodb.PurposeReset, ps.ByName("token"))
+	if err == mongodb.ErrInvalidToken {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get user by reset token", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	if err := auth.SecondFactor(db, user, r); err != nil {
+		level.Info(logger).Log("msg", "missing or invalid two-factor code", "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	// a concurrent request may have used the token since it was read
+	_, err = db.ConsumeUserToken(mongodb.PurposeReset, ps.ByName("token"))
+	if err == mongodb.ErrInvalidToken {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to consume reset token", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	if err := db.ResetPassword(user.ID, body.Password); err != nil {
+		level.Error(logger).Log("msg", "failed to reset password", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+}
+
+// Update the mutable fields of a user. A new email address only replaces the current one
+// once it has been confirmed through the link sent to it.
+func (u *User) Update(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
 	if !ok {
-		u.logger.Log("failed to get user authentication", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if userID != user.ID {
-		u.logger.Log("auth user ID did not match request ID", "request_id", ps.ByName("id"), "auth_id", userID)
-		w.WriteHeader(http.StatusBadRequest)
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
 		return
 	}
 
-	// TODO(coyle): dispatch email after mail service is completed
+	if body.Preferences != nil {
+		_, err := db.UpdatePreferences(user.ID, *body.Preferences)
+		if err == mongodb.ErrInvalidPreferences {
+			apierror.Write(w, err)
+			return
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to update preferences", "err", err, "account", user.ID)
+			apierror.Write(w, err)
+			return
+		}
+	}
+
+	if body.Email != "" && body.Email != user.Email {
+		token, err := db.RequestEmailChange(user.ID, body.Email)
+		if err == mongodb.ErrInvalidID || err == mongodb.ErrEmailExists {
+			apierror.Write(w, err)
+			return
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to request email change", "err", err, "account", user.ID)
+			apierror.Write(w, err)
+			return
+		}
+
+		go u.dispatch(logger, u.templates.EmailChange, body.Email, token)
+	}
+
+	u.writeUser(w, r, http.StatusOK, user.ID)
+}
 
-	if err := u.db.DeactivateUser(user.ID); err != nil {
-		u.logger.Log("failed to deactivate user", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusInternalServerError)
+// ConfirmEmailChange replaces a user's email address with the one the token was sent to
+func (u *User) ConfirmEmailChange(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, err := db.ConfirmEmailChange(ps.ByName("token"))
+	if err == mongodb.ErrInvalidToken || err == mongodb.ErrNoPendingEmail || err == mongodb.ErrEmailExists {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to confirm email change", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
@@ -176,84 +408,479 @@ func (u *User) Remove(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	w.WriteHeader(http.StatusOK)
 
 	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+}
 
+// Usage returns the current upload and download counters for a user
+func (u *User) Usage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	if _, ok := u.authorize(w, r, ps.ByName("id")); !ok {
+		return
+	}
+
+	usage, err := db.GetUsage(ps.ByName("id"))
+	if err == mgo.ErrNotFound {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get usage", "err", err, "account", ps.ByName("id"))
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(usage)
 }
 
-// ConfirmDeactivation of a user
-func (u *User) ConfirmDeactivation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	user, err := u.db.GetUserByToken("deactivator", ps.ByName("token"))
+// AddPaymentProcessor registers the user with a payment processor and saves it on the user
+func (u *User) AddPaymentProcessor(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	body, err := getBody(r)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	adapter, err := u.processors.Get(body.Processor)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	processor, err := adapter.Register(user, body.Data)
+	if err == payments.ErrDeclined || err == payments.ErrInvalidData {
+		level.Info(logger).Log("msg", "payment processor rejected registration", "err", err, "processor", body.Processor)
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to register with payment processor", "err", err, "processor", body.Processor)
+		apierror.Write(w, apierror.New(http.StatusBadGateway, "payment processor unavailable"))
+		return
+	}
+
+	err = db.AddPaymentProcessor(user.ID, *processor)
+	if err == mongodb.ErrProcessorExists {
+		adapter.Unregister(user, processor)
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to add payment processor", "err", err, "account", user.ID)
+		adapter.Unregister(user, processor)
+		apierror.Write(w, err)
+		return
+	}
+
+	u.writeUser(w, r, http.StatusCreated, user.ID)
+}
+
+// RemovePaymentProcessor unregisters the user from a payment processor and removes it from the user
+func (u *User) RemovePaymentProcessor(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	var processor *mongodb.PaymentProcessor
+	for i := range user.PaymentProcessors {
+		if user.PaymentProcessors[i].Name == ps.ByName("processor") {
+			processor = &user.PaymentProcessors[i]
+		}
+	}
+	if processor == nil {
+		apierror.Write(w, mongodb.ErrProcessorNotFound)
+		return
+	}
+
+	if adapter, err := u.processors.Get(processor.Name); err == nil {
+		if err := adapter.Unregister(user, processor); err != nil {
+			level.Error(logger).Log("msg", "failed to unregister from payment processor", "err", err, "processor", processor.Name)
+			apierror.Write(w, apierror.New(http.StatusBadGateway, "payment processor unavailable"))
+			return
+		}
+	}
+
+	err := db.RemovePaymentProcessor(user.ID, processor.Name)
+	if err == mongodb.ErrProcessorNotFound {
+		apierror.Write(w, err)
+		return
+	}
 	if err != nil {
-		u.logger.Log("failed to get user", "token", ps.ByName("token"))
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to remove payment processor", "err", err, "account", user.ID)
+		apierror.Write(w, err)
 		return
 	}
 
-	if err := u.db.ConfirmUserDeactivation(user.ID); err != nil {
-		u.logger.Log("failed to confirm deactivate user", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusInternalServerError)
+	u.writeUser(w, r, http.StatusOK, user.ID)
+}
+
+// SetDefaultPaymentProcessor marks one of the user's payment processors as their default
+func (u *User) SetDefaultPaymentProcessor(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	err := db.SetDefaultPaymentProcessor(user.ID, ps.ByName("processor"))
+	if err == mongodb.ErrProcessorNotFound {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to set default payment processor", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	u.writeUser(w, r, http.StatusOK, user.ID)
+}
+
+// GetPreferences returns the user's preferences
+func (u *User) GetPreferences(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+	json.NewEncoder(w).Encode(user.Preferences)
+}
+
+// UpdatePreferences changes the preferences present in the request body and leaves the rest unchanged
+func (u *User) UpdatePreferences(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+	body := mongodb.PreferencesUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
+		return
+	}
+
+	preferences, err := db.UpdatePreferences(user.ID, body)
+	if err == mongodb.ErrInvalidPreferences {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to update preferences", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(preferences)
 }
 
-// CreatePasswordResetToken for a user
-func (u *User) CreatePasswordResetToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	user, err := u.db.GetUser(ps.ByName("id"))
+// Invoices returns the user's debits grouped into one invoice per billing period
+func (u *User) Invoices(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	debits, err := db.ListDebits(user.ID)
 	if err != nil {
-		u.logger.Log("failed to get user", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to list debits", "err", err, "account", user.ID)
+		apierror.Write(w, err)
 		return
 	}
 
-	_, err = u.db.CreatePasswordResetToken(user.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(billing.Invoices(debits))
+}
+
+// Export returns everything stored about a user as a JSON document, or as a zip archive
+// with one JSON file per collection when format=zip is requested
+func (u *User) Export(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	export, err := db.ExportUser(user.ID)
 	if err != nil {
-		u.logger.Log("failed to create password reset token", "ID", ps.ByName("id"))
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to export user", "err", err, "account", user.ID)
+		apierror.Write(w, err)
 		return
 	}
 
-	// TODO(coyle): dispatch email after mail service is completed
+	name := fmt.Sprintf("bridge-export-%s", user.UUID)
+
+	if r.URL.Query().Get("format") == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeExportZip(w, export); err != nil {
+			level.Error(logger).Log("msg", "failed to write export archive", "err", err, "account", user.ID)
+		}
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
 	w.WriteHeader(http.StatusOK)
 
-	json.NewEncoder(w).Encode(mongodb.UserToView(user))
+	json.NewEncoder(w).Encode(export)
 }
 
-// ConfirmPasswordReset for a user
-func (u *User) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+// EnrollTOTP generates a new TOTP secret for the user. Two-factor is not required until the
+// secret is confirmed with EnableTOTP.
+func (u *User) EnrollTOTP(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to generate totp secret", "err", err)
+		apierror.Write(w, err)
+		return
+	}
+
+	err = db.SetTOTPSecret(user.ID, secret)
+	if err == mongodb.ErrTOTPEnabled {
+		apierror.Write(w, err)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to store totp secret", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret": secret,
+		"uri":    totp.URI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTOTP verifies a code for the enrolled secret, turns on two-factor, and returns the
+// user's recovery codes. The codes are only ever shown in this response.
+func (u *User) EnableTOTP(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
 	body, err := getBody(r)
 	if err != nil {
-		u.logger.Log("unable to get request body")
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Info(logger).Log("msg", "invalid request body", "err", err)
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid request body"))
 		return
 	}
 
-	user, err := u.db.GetUserByToken("resetter", ps.ByName("token"))
+	if user.TOTPEnabled {
+		apierror.Write(w, mongodb.ErrTOTPEnabled)
+		return
+	}
+
+	step, ok := totp.Match(user.TOTPSecret, body.Code, time.Now())
+	if user.TOTPSecret == "" || !ok {
+		apierror.Write(w, apierror.New(http.StatusBadRequest, "invalid two-factor code"))
+		return
+	}
+
+	codes, err := totp.RecoveryCodes(recoveryCodeCount)
 	if err != nil {
-		u.logger.Log("failed to find user")
-		w.WriteHeader(http.StatusInternalServerError)
+		level.Error(logger).Log("msg", "failed to generate recovery codes", "err", err)
+		apierror.Write(w, err)
 		return
 	}
 
-	if err := u.db.ResetPassword(user.ID, body.Password); err != nil {
-		u.logger.Log("failed to reset password")
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := db.EnableTOTP(user.ID, codes, step); err != nil {
+		level.Error(logger).Log("msg", "failed to enable totp", "err", err, "account", user.ID)
+		apierror.Write(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
+	json.NewEncoder(w).Encode(map[string][]string{"recoveryCodes": codes})
+}
+
+// DisableTOTP turns off two-factor for the user. The request must already carry a valid code.
+func (u *User) DisableTOTP(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	user, ok := u.authorize(w, r, ps.ByName("id"))
+	if !ok {
+		return
+	}
+
+	if err := db.DisableTOTP(user.ID); err != nil {
+		level.Error(logger).Log("msg", "failed to disable totp", "err", err, "account", user.ID)
+		apierror.Write(w, err)
+		return
+	}
+
+	u.writeUser(w, r, http.StatusOK, user.ID)
+}
+
+// authorize authenticates the request and returns the user with the provided ID if the
+// requester is that user or an administrator. Otherwise it writes the failure status.
+func (u *User) authorize(w http.ResponseWriter, r *http.Request, id string) (*mongodb.User, bool) {
+	logger := logging.FromContext(r.Context())
+	db := u.db.WithLogger(logger)
+
+	requester, err := auth.BasicAuth(db, r)
+	if err != nil {
+		level.Info(logger).Log("msg", "failed to authenticate user", "err", err, "account", id)
+		apierror.Write(w, err)
+		return nil, false
+	}
+
+	if requester.ID == id || requester.Email == id {
+		return requester, true
+	}
+
+	if !requester.IsAdmin {
+		level.Info(logger).Log("msg", "user may not access account", "account", id)
+		apierror.Write(w, auth.ErrForbidden)
+		return nil, false
+	}
+
+	user, err := db.LookupUser(id)
+	if err == mgo.ErrNotFound {
+		apierror.Write(w, err)
+		return nil, false
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get user", "err", err, "account", id)
+		apierror.Write(w, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// writeUser reloads the user with the provided ID and writes its view
+func (u *User) writeUser(w http.ResponseWriter, r *http.Request, status int, id string) {
+	logger := logging.FromContext(r.Context())
+
+	user, err := u.db.WithLogger(logger).GetUser(id)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get user", "err", err, "account", id)
+		apierror.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
 	json.NewEncoder(w).Encode(mongodb.UserToView(user))
 }
 
-func (u *User) dispatchActivationEmailSwitch(usr mongodb.User) {
-	return
+// dispatchActivationEmailSwitch issues a new activation token and emails it unless the user is already active
+func (u *User) dispatchActivationEmailSwitch(logger log.Logger, usr mongodb.User) {
+	db := u.db.WithLogger(logger)
+	if usr.Activated {
+		return
+	}
+
+	token, err := db.IssueUserToken(usr.ID, mongodb.PurposeActivation)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to issue activation token", "err", err, "account", usr.ID)
+		return
+	}
+
+	u.dispatch(logger, u.templates.Activation, usr.Email, token)
+}
+
+// dispatch renders an email for the recipient and token and hands it to the mailer
+func (u *User) dispatch(logger log.Logger, render func(to, token string) (mailer.Message, error), to, token string) {
+	m, err := render(to, token)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to render email", "err", err, "to", to)
+		return
+	}
+
+	if err := u.mailer.Send(m); err != nil {
+		level.Error(logger).Log("msg", "failed to send email", "err", err, "to", to, "subject", m.Subject)
+	}
+}
+
+// writeExportZip writes each part of the export to its own JSON file in a zip archive
+func writeExportZip(w io.Writer, e *mongodb.UserExport) error {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", e.User},
+		{"usage.json", e.Usage},
+		{"publickeys.json", e.PublicKeys},
+		{"buckets.json", e.Buckets},
+		{"files.json", e.Files},
+		{"frames.json", e.Frames},
+		{"exchangereports.json", e.ExchangeReports},
+	}
+
+	z := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: e.Generated})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
 }
 
 func getBody(r *http.Request) (Request, error) {
@@ -1,32 +1,52 @@
 package users
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/coyle/bridge/client"
 	"github.com/coyle/bridge/storage/mongodb"
-	"github.com/google/uuid"
-	secp256k1 "github.com/haltingstate/secp256k1-go"
+	passwd "github.com/coyle/bridge/storage/password"
 	"github.com/stretchr/testify/assert"
 )
 
+const bridgeURL = "http://bridge-server:8080"
+
 func init() {
-	waitUntilReady("http://bridge-server:8080/health")
+	waitUntilReady(bridgeURL + "/health")
 }
 
 var password = sha256.Sum256([]byte("password"))
-var pubKey, _ = secp256k1.GenerateKeyPair()
-var pubKeyString = hex.EncodeToString(pubKey)
+var pubKeyString = client.GenerateKey().PublicHex()
+
+// newClient returns a client for the test bridge, authenticated as the user if email is set
+func newClient(t *testing.T, email, password string) *client.Client {
+	c, err := client.New(bridgeURL)
+	assert.NoError(t, err)
+
+	if email != "" {
+		c.SetBasicAuth(email, password)
+	}
+
+	return c
+}
+
+// statusCode returns the status the bridge responded with, treating success as expected
+func statusCode(err error, success int) int {
+	if err == nil {
+		return success
+	}
+
+	return client.StatusCode(err)
+}
 
 func TestCreate(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 
 	if err != nil {
 		t.Errorf("unable to connect to Mongo: %s", err)
@@ -34,7 +54,7 @@ func TestCreate(t *testing.T) {
 
 	cases := []struct {
 		name                 string
-		body                 []byte
+		registration         client.Registration
 		id                   string
 		expectedResponseCode int
 		expectedError        bool
@@ -42,15 +62,15 @@ func TestCreate(t *testing.T) {
 	}{
 		{
 			"valid user createtion request",
-			[]byte(fmt.Sprintf(`{"email":"test@storj.io", "password":"%x", "pubkey": "%s"}`, password, pubKeyString)),
+			client.Registration{Email: "test@storj.io", Password: "password", PublicKey: pubKeyString},
 			"test@storj.io",
 			201,
 			false,
-			mongodb.User{ID: "test@storj.io", Hashpass: fmt.Sprintf("%x", password)},
+			mongodb.User{Email: "test@storj.io", Hashpass: fmt.Sprintf("%x", password)},
 		},
 		{
 			"invalid email",
-			[]byte(fmt.Sprintf(`{"email":"test+storj.io", "password":"%x", "pubkey": "%s"}`, password, pubKeyString)),
+			client.Registration{Email: "test+storj.io", Password: "password", PublicKey: pubKeyString},
 			"test+storj.io",
 			400,
 			true,
@@ -58,94 +78,65 @@ func TestCreate(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		req, _ := http.NewRequest("POST", "http://bridge-server:8080/users", bytes.NewBuffer(c.body))
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		err := newClient(t, "", "").Register(context.Background(), c.registration)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusCreated), c.name)
 
 		if c.expectedError {
 			continue
 		}
 
-		actualUser, err := storageClient.GetUser(c.id)
+		actualUser, err := storageClient.GetUserByEmail(c.id)
 		assert.NoError(t, err)
-		assert.Equal(t, c.id, actualUser.ID)
-		assert.Equal(t, fmt.Sprintf("%x", password), actualUser.Hashpass)
+		assert.Equal(t, c.id, actualUser.Email)
+		assert.Equal(t, actualUser.UUID, actualUser.ID)
+		assert.NotEqual(t, fmt.Sprintf("%x", password), actualUser.Hashpass)
+		ok, err := passwd.Verify(actualUser.HashAlgorithm, actualUser.Hashpass, fmt.Sprintf("%x", password))
+		assert.NoError(t, err)
+		assert.True(t, ok)
 		assert.WithinDuration(t, time.Now(), actualUser.Created, 1*time.Minute)
 	}
 }
 
 func TestReactivate(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
-	testUser := mongodb.TestUser(false)
-	_, err = storageClient.CreateUser(*testUser)
+	inactive := mongodb.TestUser(false)
+	_, err = storageClient.CreateUser(*inactive)
 	assert.NoError(t, err)
 
+	active := mongodb.TestUser(true)
+	_, err = storageClient.CreateUser(*active)
+	assert.NoError(t, err)
+
+	// every address gets the same answer, so registered ones can not be told apart
 	cases := []struct {
-		name                 string
-		body                 []byte
-		id                   string
-		expectedResponseCode int
-		expectedError        bool
-		expectedUser         mongodb.User
+		name  string
+		email string
 	}{
-		{
-			name:                 "valid user reactivation",
-			body:                 []byte(fmt.Sprintf(`{"email":"%s"}`, testUser.ID)),
-			expectedResponseCode: http.StatusCreated,
-		},
+		{name: "inactive user", email: inactive.Email},
+		{name: "active user", email: active.Email},
+		{name: "unknown email", email: "unknown-" + inactive.Email},
 	}
 
 	for _, c := range cases {
-		req, _ := http.NewRequest("POST", "http://bridge-server:8080/activations", bytes.NewBuffer(c.body))
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
-
-		if c.expectedError {
-			continue
-		}
-
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
-		// assert the user's private fields are omitted
-		assert.Empty(t, u.ID)
-		assert.Empty(t, u.Hashpass)
-		assert.Empty(t, u.Activator)
-		assert.Empty(t, u.Deactivator)
-		assert.Empty(t, u.Resetter)
-		assert.Empty(t, u.BytesDownloaded)
-		assert.Empty(t, u.BytesUploaded)
-
-		// assert expected fields are there
-		assert.Equal(t, testUser.UUID, u.UUID)
-		assert.Equal(t, testUser.IsFreeTier, u.IsFreeTier)
-		assert.Equal(t, testUser.Activated, u.Activated)
-		assert.WithinDuration(t, testUser.Created, u.Created, 1*time.Second)
+		err := newClient(t, "", "").ResendActivation(context.Background(), c.email)
+		assert.Equal(t, http.StatusOK, statusCode(err, http.StatusOK), c.name)
 	}
 }
 
 func TestConfirmActivation(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
 	testUser := mongodb.TestUser(false)
-	testUser.Activator = testUser.UUID
 
 	_, err = storageClient.CreateUser(*testUser)
 	assert.NoError(t, err)
 
+	activator, err := storageClient.IssueUserToken(testUser.ID, mongodb.PurposeActivation)
+	assert.NoError(t, err)
+
 	cases := []struct {
 		name                 string
 		id                   string
@@ -156,29 +147,19 @@ func TestConfirmActivation(t *testing.T) {
 	}{
 		{
 			name:                 "valid user activation",
-			activator:            testUser.Activator,
+			activator:            activator,
 			expectedResponseCode: http.StatusOK,
 		},
 	}
 
 	for _, c := range cases {
-		url := fmt.Sprintf("http://bridge-server:8080/activations/%s", c.activator)
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		u, err := newClient(t, "", "").ConfirmActivation(context.Background(), c.activator)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusOK), c.name)
 
 		if c.expectedError {
 			continue
 		}
 
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
 		// assert the user's private fields are omitted
 		assert.Empty(t, u.ID)
 		assert.Empty(t, u.Hashpass)
@@ -201,7 +182,7 @@ func TestConfirmActivation(t *testing.T) {
 }
 
 func TestDeactivation(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
 	testUser := mongodb.TestUser(true)
@@ -213,6 +194,7 @@ func TestDeactivation(t *testing.T) {
 		name                 string
 		id                   string
 		username             string
+		password             string
 		activator            string
 		expectedResponseCode int
 		expectedError        bool
@@ -220,10 +202,19 @@ func TestDeactivation(t *testing.T) {
 		expectedDeactivated  bool
 		expectedActivated    bool
 	}{
+		{
+			name:                 "wrong password",
+			id:                   testUser.ID,
+			username:             testUser.Email,
+			password:             "wrong password",
+			expectedResponseCode: http.StatusUnauthorized,
+			expectedError:        true,
+		},
 		{
 			name:                 "valid user deactivation",
 			id:                   testUser.ID,
-			username:             testUser.ID,
+			username:             testUser.Email,
+			password:             "password",
 			expectedResponseCode: http.StatusOK,
 			expectedDeactivated:  false,
 			expectedActivated:    true,
@@ -231,23 +222,13 @@ func TestDeactivation(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		url := fmt.Sprintf("http://bridge-server:8080/users/%s", c.id)
-		req, _ := http.NewRequest("DELETE", url, nil)
-		req.SetBasicAuth(c.username, "passwd")
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		u, err := newClient(t, c.username, c.password).Deactivate(context.Background(), c.id)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusOK), c.name)
 
 		if c.expectedError {
 			continue
 		}
 
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
 		// assert the user's private fields are omitted
 		assert.Empty(t, u.ID)
 		assert.Empty(t, u.Hashpass)
@@ -264,14 +245,16 @@ func TestDeactivation(t *testing.T) {
 
 		us, err := storageClient.GetUser(testUser.ID)
 		assert.NoError(t, err)
-		assert.NotEmpty(t, us.Deactivator)
+		tokens, err := storageClient.TestUserTokens(testUser.ID, mongodb.PurposeDeactivation)
+		assert.NoError(t, err)
+		assert.Len(t, tokens, 1)
 		assert.Equal(t, c.expectedDeactivated, us.Deactivated)
 		assert.Equal(t, c.expectedActivated, us.Activated)
 	}
 }
 
 func TestConfirmDeactivation(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
 	testUser := mongodb.TestUser(false)
@@ -281,6 +264,9 @@ func TestConfirmDeactivation(t *testing.T) {
 	_, err = storageClient.CreateUser(*testUser)
 	assert.NoError(t, err)
 
+	deactivator, err := storageClient.IssueUserToken(testUser.ID, mongodb.PurposeDeactivation)
+	assert.NoError(t, err)
+
 	cases := []struct {
 		name                 string
 		id                   string
@@ -292,7 +278,7 @@ func TestConfirmDeactivation(t *testing.T) {
 	}{
 		{
 			name:                 "valid user deactivation confirmation",
-			id:                   testUser.Deactivator,
+			id:                   deactivator,
 			expectedResponseCode: http.StatusOK,
 			expectedDeactivated:  true,
 			expectedActivated:    false,
@@ -300,22 +286,13 @@ func TestConfirmDeactivation(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		url := fmt.Sprintf("http://bridge-server:8080//deactivations//%s", c.id)
-		req, _ := http.NewRequest("GET", url, nil)
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		u, err := newClient(t, "", "").ConfirmDeactivation(context.Background(), c.id)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusOK), c.name)
 
 		if c.expectedError {
 			continue
 		}
 
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
 		// assert the user's private fields are omitted
 		assert.Empty(t, u.ID)
 		assert.Empty(t, u.Hashpass)
@@ -332,14 +309,14 @@ func TestConfirmDeactivation(t *testing.T) {
 
 		us, err := storageClient.GetUser(testUser.ID)
 		assert.NoError(t, err)
-		assert.NotEmpty(t, us.Activator)
+		assert.Empty(t, us.Activator)
 		assert.Equal(t, c.expectedDeactivated, us.Deactivated)
 		assert.Equal(t, c.expectedActivated, us.Activated)
 	}
 }
 
 func TestPasswordResetToken(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
 	testUser := mongodb.TestUser(true)
@@ -350,99 +327,76 @@ func TestPasswordResetToken(t *testing.T) {
 	cases := []struct {
 		name                 string
 		id                   string
-		activator            string
+		user                 string
 		expectedResponseCode int
-		expectedError        bool
+		expectedTokens       int
 	}{
 		{
 			name:                 "valid user password reset",
-			id:                   testUser.ID,
+			id:                   testUser.Email,
+			user:                 testUser.ID,
+			expectedResponseCode: http.StatusOK,
+			expectedTokens:       1,
+		},
+		{
+			name:                 "unknown email answers the same",
+			id:                   "unknown-" + testUser.Email,
 			expectedResponseCode: http.StatusOK,
 		},
 	}
 
 	for _, c := range cases {
-		url := fmt.Sprintf("http://bridge-server:8080/users/%s", c.id)
-		req, _ := http.NewRequest("PATCH", url, nil)
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		err := newClient(t, "", "").RequestPasswordReset(context.Background(), c.id)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusOK), c.name)
 
-		if c.expectedError {
+		if c.user == "" {
 			continue
 		}
 
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
-		// assert the user's private fields are omitted
-		assert.Empty(t, u.ID)
-		assert.Empty(t, u.Hashpass)
-		assert.Empty(t, u.Activator)
-		assert.Empty(t, u.Deactivator)
-		assert.Empty(t, u.Resetter)
-		assert.Empty(t, u.BytesDownloaded)
-		assert.Empty(t, u.BytesUploaded)
-
-		// assert expected fields are there
-		assert.Equal(t, testUser.UUID, u.UUID)
-		assert.Equal(t, testUser.IsFreeTier, u.IsFreeTier)
-		assert.WithinDuration(t, testUser.Created, u.Created, 1*time.Second)
-
-		us, err := storageClient.GetUser(testUser.ID)
+		tokens, err := storageClient.TestUserTokens(c.user, mongodb.PurposeReset)
 		assert.NoError(t, err)
-		assert.NotEmpty(t, us.Resetter)
+		assert.Len(t, tokens, c.expectedTokens)
 	}
 }
 
 func TestConfirmPasswordReset(t *testing.T) {
-	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"))
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
 	assert.NoError(t, err)
 
 	testUser := mongodb.TestUser(true)
-	testUser.Resetter = uuid.New().String()
 
 	_, err = storageClient.CreateUser(*testUser)
 	assert.NoError(t, err)
 
+	resetter, err := storageClient.IssueUserToken(testUser.ID, mongodb.PurposeReset)
+	assert.NoError(t, err)
+
 	cases := []struct {
 		name                 string
 		id                   string
-		body                 []byte
+		password             string
 		activator            string
 		expectedResponseCode int
 		expectedError        bool
-		expectedPassword     []byte
+		expectedPassword     string
 	}{
 		{
 			name:                 "valid user password reset confirmation",
-			id:                   testUser.Resetter,
-			body:                 []byte(`{"password":"password"}`),
+			id:                   resetter,
+			password:             "new password",
 			expectedResponseCode: http.StatusOK,
-			expectedPassword:     []byte("password"),
+			expectedPassword:     client.HashPassword("new password"),
 		},
 	}
 
 	for _, c := range cases {
-		url := fmt.Sprintf("http://bridge-server:8080/resets/%s", c.id)
-		req, _ := http.NewRequest("POST", url, bytes.NewBuffer(c.body))
-
-		client := &http.Client{}
-		resp, _ := client.Do(req)
-		if resp.StatusCode != c.expectedResponseCode {
-			assert.Equal(t, c.expectedResponseCode, resp.StatusCode)
-		}
+		u, err := newClient(t, "", "").ConfirmPasswordReset(context.Background(), c.id, c.password)
+		assert.Equal(t, c.expectedResponseCode, statusCode(err, http.StatusOK), c.name)
 
 		if c.expectedError {
 			continue
 		}
 
-		u := mongodb.User{}
-		json.NewDecoder(resp.Body).Decode(&u)
-
 		// assert the user's private fields are omitted
 		assert.Empty(t, u.ID)
 		assert.Empty(t, u.Hashpass)
@@ -460,10 +414,31 @@ func TestConfirmPasswordReset(t *testing.T) {
 		us, err := storageClient.GetUser(testUser.ID)
 		assert.NoError(t, err)
 		assert.Empty(t, us.Resetter)
-		h := sha256.New()
-		h.Write(c.expectedPassword)
-		assert.Equal(t, us.Hashpass, fmt.Sprintf("%x", h.Sum(nil)))
+		ok, err := passwd.Verify(us.HashAlgorithm, us.Hashpass, c.expectedPassword)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+}
+
+func TestExport(t *testing.T) {
+	storageClient, err := mongodb.NewClient(os.Getenv("MONGO"), mongodb.DefaultDatabase)
+	assert.NoError(t, err)
+
+	testUser := mongodb.TestUser(true)
+
+	_, err = storageClient.CreateUser(*testUser)
+	assert.NoError(t, err)
+
+	e, err := newClient(t, testUser.Email, "password").Export(context.Background(), testUser.ID)
+	if !assert.NoError(t, err) {
+		return
 	}
+
+	assert.Equal(t, testUser.ID, e.User.ID)
+	assert.Equal(t, testUser.Email, e.User.Email)
+	assert.Empty(t, e.User.Hashpass)
+	assert.NotNil(t, e.PublicKeys)
+	assert.NotNil(t, e.Buckets)
 }
 
 func TestDispatchActivationEmailSwitch(t *testing.T) {
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// secret is the RFC 6238 SHA1 test key
+var secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	cases := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, c := range cases {
+		code, err := Code(secret, time.Unix(c.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, code, c.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	cases := []struct {
+		name     string
+		code     string
+		at       time.Time
+		expected bool
+	}{
+		{name: "current period", code: "005924", at: now, expected: true},
+		{name: "previous period", code: "005924", at: now.Add(Period), expected: true},
+		{name: "next period", code: "005924", at: now.Add(-Period), expected: true},
+		{name: "outside skew", code: "005924", at: now.Add(2 * Period)},
+		{name: "wrong code", code: "123456", at: now},
+		{name: "wrong length", code: "5924", at: now},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, Validate(secret, c.code, c.at), c.name)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / int64(Period.Seconds())
+
+	matched, ok := Match(secret, "005924", now.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, step, matched, "the step the code was generated for, not the current one")
+
+	_, ok = Match(secret, "123456", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Storj Bridge", "user@storj.io", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Storj%20Bridge:user@storj.io?"))
+	assert.Contains(t, uri, "secret=ABC")
+}
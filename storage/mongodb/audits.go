@@ -0,0 +1,36 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AuditUserPurged is recorded when a deactivated user and all of their data is deleted
+	AuditUserPurged = "user.purged"
+)
+
+// Audit defines the audit entry schema in the audits collection
+type Audit struct {
+	ID      string         `bson:"_id" json:"id"`
+	Action  string         `json:"action"`
+	Subject string         `json:"subject"`
+	Details map[string]int `json:"details,omitempty"`
+	Created time.Time      `json:"created"`
+}
+
+// CreateAudit records an action taken against the subject
+func (c *Client) CreateAudit(action, subject string, details map[string]int) (*Audit, error) {
+	defer c.observe("CreateAudit")()
+
+	a := &Audit{
+		ID:      uuid.New().String(),
+		Action:  action,
+		Subject: subject,
+		Details: details,
+		Created: time.Now().UTC(),
+	}
+
+	return a, c.audits.Insert(a)
+}
@@ -0,0 +1,35 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// BucketEntry defines the file schema in the bucketentries collection
+type BucketEntry struct {
+	ID       string    `bson:"_id" json:"id"`
+	Bucket   string    `json:"bucket"`
+	Frame    string    `json:"frame"`
+	Name     string    `json:"filename"`
+	MimeType string    `json:"mimetype"`
+	Created  time.Time `json:"created"`
+}
+
+// GetBucketEntry queries for the file with the provided ID in a bucket
+func (c *Client) GetBucketEntry(bucket, id string) (*BucketEntry, error) {
+	defer c.observe("GetBucketEntry")()
+
+	e := &BucketEntry{}
+	err := c.bucketEntries.Find(bson.M{"_id": id, "bucket": bucket}).One(e)
+	return e, err
+}
+
+// ListBucketEntries returns all files in the buckets with the provided IDs
+func (c *Client) ListBucketEntries(buckets []string) ([]BucketEntry, error) {
+	defer c.observe("ListBucketEntries")()
+
+	entries := []BucketEntry{}
+	err := c.bucketEntries.Find(bson.M{"bucket": bson.M{"$in": buckets}}).All(&entries)
+	return entries, err
+}
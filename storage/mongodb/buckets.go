@@ -1,8 +1,10 @@
 package mongodb
 
+import "github.com/globalsign/mgo/bson"
+
 // Bucket defines the bucket schema in the buckets collection
 type Bucket struct {
-	ID       string   `json:"_id"`
+	ID       string   `bson:"_id" json:"_id"`
 	User     string   `json:"user"`
 	Created  string   `json:"created"`
 	Name     string   `json:"name"`
@@ -11,3 +13,21 @@ type Bucket struct {
 	Transfer int      `json:"transfer"`
 	Storage  int      `json:"storage"`
 }
+
+// GetBucket queries for a bucket by its ID
+func (c *Client) GetBucket(id string) (*Bucket, error) {
+	defer c.observe("GetBucket")()
+
+	b := &Bucket{}
+	err := c.buckets.Find(bson.M{"_id": id}).One(b)
+	return b, err
+}
+
+// ListBuckets returns all buckets owned by the user with the provided ID
+func (c *Client) ListBuckets(user string) ([]Bucket, error) {
+	defer c.observe("ListBuckets")()
+
+	buckets := []Bucket{}
+	err := c.buckets.Find(bson.M{"user": user}).All(&buckets)
+	return buckets, err
+}
@@ -1,29 +1,157 @@
 package mongodb
 
 import (
+	"time"
+
 	"github.com/globalsign/mgo"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 )
 
 // Client is the mongoDB implementation of the DB interface
 type Client struct {
-	session    *mgo.Session
-	users      *mgo.Collection
-	partners   *mgo.Collection
-	publicKeys *mgo.Collection
+	session         *mgo.Session
+	users           *mgo.Collection
+	partners        *mgo.Collection
+	publicKeys      *mgo.Collection
+	buckets         *mgo.Collection
+	bucketEntries   *mgo.Collection
+	frames          *mgo.Collection
+	tokens          *mgo.Collection
+	userTokens      *mgo.Collection
+	shards          *mgo.Collection
+	exchangeReports *mgo.Collection
+	debits          *mgo.Collection
+	billingRuns     *mgo.Collection
+	audits          *mgo.Collection
+	rateLimits      *mgo.Collection
+	contacts        *mgo.Collection
+	stats           *mgo.Collection
+	observer        func(operation string, d time.Duration)
+	logger          log.Logger
 }
 
-// NewClient instantiates a connection to our MongoDB server
-func NewClient(url string) (*Client, error) {
+// DefaultDatabase is the database bridge collections are kept in unless configured otherwise
+const DefaultDatabase = "bridge"
+
+// NewClient instantiates a connection to our MongoDB server using the named database
+func NewClient(url, database string) (*Client, error) {
 	session, err := mgo.Dial(url)
 	if err != nil {
 		return nil, err
 	}
 
+	db := session.DB(database)
+
 	return &Client{
-		session:    session,
-		users:      session.DB("bridge").C("users"),
-		partners:   session.DB("bridge").C("partners"),
-		publicKeys: session.DB("bridge").C("publickeys"),
+		session:         session,
+		users:           db.C("users"),
+		partners:        db.C("partners"),
+		publicKeys:      db.C("publickeys"),
+		buckets:         db.C("buckets"),
+		bucketEntries:   db.C("bucketentries"),
+		frames:          db.C("frames"),
+		tokens:          db.C("tokens"),
+		userTokens:      db.C("usertokens"),
+		shards:          db.C("shards"),
+		exchangeReports: db.C("exchangereports"),
+		debits:          db.C("debits"),
+		billingRuns:     db.C("billingruns"),
+		audits:          db.C("audits"),
+		rateLimits:      db.C("ratelimits"),
+		contacts:        db.C("contacts"),
+		stats:           db.C("stats"),
 	}, nil
+}
+
+// maxConnectBackoff caps the wait between connection attempts in Connect
+const maxConnectBackoff = 30 * time.Second
+
+// Connect calls NewClient until it succeeds or timeout has passed, doubling the wait between
+// attempts. retry is called with each failure and the wait before the next attempt. A zero
+// timeout makes a single attempt.
+func Connect(url, database string, timeout time.Duration, retry func(err error, wait time.Duration)) (*Client, error) {
+	deadline := time.Now().Add(timeout)
+	wait := 500 * time.Millisecond
+
+	for {
+		c, err := NewClient(url, database)
+		if err == nil {
+			return c, nil
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			return nil, err
+		}
+
+		retry(err, wait)
+		time.Sleep(wait)
+
+		if wait *= 2; wait > maxConnectBackoff {
+			wait = maxConnectBackoff
+		}
+	}
+}
+
+// SetObserver registers a function that is called with the name and duration of every
+// Client operation. It must be set before the Client is shared.
+func (c *Client) SetObserver(observer func(operation string, d time.Duration)) {
+	c.observer = observer
+}
+
+// WithLogger returns a copy of the Client that logs its operations to logger, such as the
+// logger of the request they are made for. The copy shares the original's connection, which
+// only the original should close.
+func (c *Client) WithLogger(logger log.Logger) *Client {
+	cp := *c
+	cp.logger = logger
+
+	return &cp
+}
+
+// unobserved returns a copy of the Client that neither observes nor logs its operations.
+// Operations built from other operations call them through it, so only the outer operation
+// is timed.
+func (c *Client) unobserved() *Client {
+	cp := *c
+	cp.observer = nil
+	cp.logger = nil
+
+	return &cp
+}
+
+// observe starts timing an operation. The returned function reports the duration to the
+// observer, logs it at debug level, and is meant to be deferred.
+func (c *Client) observe(operation string) func() {
+	if c.observer == nil && c.logger == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+		if c.observer != nil {
+			c.observer(operation, d)
+		}
+		if c.logger != nil {
+			level.Debug(c.logger).Log("msg", "database operation", "operation", operation, "duration", d)
+		}
+	}
+}
+
+// Ping checks the MongoDB server is reachable on a fresh connection from the pool, giving up
+// after timeout
+func (c *Client) Ping(timeout time.Duration) error {
+	s := c.session.Copy()
+	defer s.Close()
+
+	s.SetSyncTimeout(timeout)
+	s.SetSocketTimeout(timeout)
+
+	return s.Ping()
+}
 
+// Close releases the connection to the MongoDB server
+func (c *Client) Close() {
+	c.session.Close()
 }
@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+	var observed []string
+	c := &Client{}
+	c.SetObserver(func(operation string, d time.Duration) { observed = append(observed, operation) })
+
+	var buf bytes.Buffer
+	logged := c.WithLogger(log.With(log.NewLogfmtLogger(&buf), "request_id", "r1"))
+
+	logged.observe("GetUser")()
+	assert.Contains(t, buf.String(), `request_id=r1 msg="database operation" operation=GetUser`)
+
+	buf.Reset()
+	c.observe("GetBucket")()
+	assert.Empty(t, buf.String(), "the original client does not log")
+	assert.Equal(t, []string{"GetUser", "GetBucket"}, observed)
+}
+
+func TestUnobserved(t *testing.T) {
+	var observed []string
+	c := &Client{}
+	c.SetObserver(func(operation string, d time.Duration) { observed = append(observed, operation) })
+
+	func() {
+		defer c.observe("LookupUser")()
+		c.unobserved().observe("GetUser")()
+	}()
+
+	assert.Equal(t, []string{"LookupUser"}, observed, "nested operations are not timed twice")
+}
@@ -2,11 +2,13 @@ package mongodb
 
 import (
 	"time"
+
+	"github.com/globalsign/mgo/bson"
 )
 
-// Contact defines the user schema
+// Contact defines the storage node schema in the contacts collection
 type Contact struct {
-	ID          string    `json:"_id"`
+	ID          string    `bson:"_id" json:"_id"`
 	LastSeen    time.Time `json:"lastSeen"`
 	Port        int       `json:"port"`
 	Address     string    `json:"address"`
@@ -15,3 +17,10 @@ type Contact struct {
 	LastTimeout time.Time `json:"lastTimeout"`
 	TimeoutRate int       `json:"timeoutRate"`
 }
+
+// CountOnlineContacts returns the number of storage nodes seen since the provided time
+func (c *Client) CountOnlineContacts(since time.Time) (int, error) {
+	defer c.observe("CountOnlineContacts")()
+
+	return c.contacts.Find(bson.M{"lastseen": bson.M{"$gte": since}}).Count()
+}
@@ -0,0 +1,139 @@
+package mongodb
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// DebitStorage is the debit type for stored data
+	DebitStorage = "storage"
+	// DebitBandwidth is the debit type for downloaded data
+	DebitBandwidth = "bandwidth"
+)
+
+// Debit defines the debit schema in the debits collection. Amount is in cents.
+type Debit struct {
+	ID          string    `bson:"_id" json:"id"`
+	User        string    `json:"user"`
+	Type        string    `json:"type"`
+	Quantity    float64   `json:"quantity"`
+	Amount      int64     `json:"amount"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Created     time.Time `json:"created"`
+}
+
+// SaveDebit inserts the debit unless one already exists for the same user, type, and period,
+// and returns the stored debit. Issued debits are never changed, so a billing run can safely
+// be repeated.
+func (c *Client) SaveDebit(d Debit) (*Debit, error) {
+	defer c.observe("SaveDebit")()
+
+	d.ID = fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", d.User, d.Type, d.PeriodStart.Unix()))))
+	if d.Created.IsZero() {
+		d.Created = time.Now().UTC()
+	}
+
+	stored := &Debit{}
+	_, err := c.debits.FindId(d.ID).Apply(mgo.Change{
+		Update: bson.M{"$setOnInsert": bson.M{
+			"user":        d.User,
+			"type":        d.Type,
+			"quantity":    d.Quantity,
+			"amount":      d.Amount,
+			"periodstart": d.PeriodStart,
+			"periodend":   d.PeriodEnd,
+			"created":     d.Created,
+		}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, stored)
+
+	return stored, err
+}
+
+// BillingRun records the billing of one period. Completed is zero until every user is billed.
+type BillingRun struct {
+	ID        string    `bson:"_id" json:"id"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `bson:",omitempty" json:"completed,omitempty"`
+}
+
+// ClaimBillingRun records that the period starting at start is being billed. It returns false
+// if the period has already been billed, or another run started billing it less than lease
+// ago, so that only one instance bills each period.
+func (c *Client) ClaimBillingRun(start time.Time, lease time.Duration) (bool, error) {
+	defer c.observe("ClaimBillingRun")()
+
+	now := time.Now().UTC()
+	_, err := c.billingRuns.Upsert(
+		bson.M{"_id": billingRunID(start), "completed": bson.M{"$exists": false}, "started": bson.M{"$lte": now.Add(-lease)}},
+		bson.M{"$set": bson.M{"started": now}},
+	)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// CompleteBillingRun records that every user has been billed for the period starting at start
+func (c *Client) CompleteBillingRun(start time.Time) error {
+	defer c.observe("CompleteBillingRun")()
+
+	return c.billingRuns.UpdateId(billingRunID(start), bson.M{"$set": bson.M{"completed": time.Now().UTC()}})
+}
+
+// BillingRunCompleted reports whether every user has been billed for the period starting at start
+func (c *Client) BillingRunCompleted(start time.Time) (bool, error) {
+	defer c.observe("BillingRunCompleted")()
+
+	n, err := c.billingRuns.Find(bson.M{"_id": billingRunID(start), "completed": bson.M{"$exists": true}}).Count()
+	return n > 0, err
+}
+
+func billingRunID(start time.Time) string {
+	return start.UTC().Format("2006-01")
+}
+
+// ListDebits returns all debits for the user ordered by period
+func (c *Client) ListDebits(user string) ([]Debit, error) {
+	defer c.observe("ListDebits")()
+
+	debits := []Debit{}
+	err := c.debits.Find(bson.M{"user": user}).Sort("periodstart", "type").All(&debits)
+	return debits, err
+}
+
+// ListPeriodDebits returns the debits issued to any of the users for the billing period
+// starting at start
+func (c *Client) ListPeriodDebits(users []string, start time.Time) ([]Debit, error) {
+	defer c.observe("ListPeriodDebits")()
+
+	debits := []Debit{}
+	err := c.debits.Find(bson.M{"user": bson.M{"$in": users}, "periodstart": start}).All(&debits)
+	return debits, err
+}
+
+// ForEachUser calls fn for every user in the users collection until fn returns an error
+func (c *Client) ForEachUser(fn func(u *User) error) error {
+	defer c.observe("ForEachUser")()
+
+	iter := c.users.Find(nil).Iter()
+
+	u := &User{}
+	for iter.Next(u) {
+		if err := fn(u); err != nil {
+			iter.Close()
+			return err
+		}
+		u = &User{}
+	}
+
+	return iter.Close()
+}
@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveDebitKeepsIssuedDebit(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := Debit{User: uuid.New().String(), Type: DebitStorage, Amount: 100, PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0)}
+
+	first, err := c.SaveDebit(d)
+	assert.NoError(t, err)
+
+	d.Amount = 50
+	again, err := c.SaveDebit(d)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), again.Amount)
+	assert.WithinDuration(t, first.Created, again.Created, time.Millisecond)
+
+	debits, err := c.ListDebits(d.User)
+	assert.NoError(t, err)
+	assert.Len(t, debits, 1)
+}
+
+func TestClaimBillingRun(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	// a period far in the past so runs of the real job never collide with the test
+	start := time.Date(1900+time.Now().Nanosecond()%100, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.billingRuns.RemoveId(billingRunID(start))
+
+	claimed, err := c.ClaimBillingRun(start, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = c.ClaimBillingRun(start, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, claimed, "a run within its lease is not taken over")
+
+	completed, err := c.BillingRunCompleted(start)
+	assert.NoError(t, err)
+	assert.False(t, completed, "a claimed run is not complete")
+
+	claimed, err = c.ClaimBillingRun(start, 0)
+	assert.NoError(t, err)
+	assert.True(t, claimed, "an expired run is taken over")
+
+	assert.NoError(t, c.CompleteBillingRun(start))
+
+	completed, err = c.BillingRunCompleted(start)
+	assert.NoError(t, err)
+	assert.True(t, completed)
+
+	claimed, err = c.ClaimBillingRun(start, 0)
+	assert.NoError(t, err)
+	assert.False(t, claimed, "a completed run is never repeated")
+}
@@ -0,0 +1,69 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+)
+
+const (
+	// ExchangeSuccess is the result code of a successful shard transfer
+	ExchangeSuccess = 1000
+	// ExchangeFailure is the result code of a failed shard transfer
+	ExchangeFailure = 1100
+
+	// ShardDownloaded is the result message reported when a client retrieved a shard
+	ShardDownloaded = "SHARD_DOWNLOADED"
+	// ShardUploaded is the result message reported when a client stored a shard
+	ShardUploaded = "SHARD_UPLOADED"
+)
+
+// ExchangeReport defines the exchange report schema in the exchangereports collection
+type ExchangeReport struct {
+	ID                    string    `bson:"_id" json:"id"`
+	DataHash              string    `json:"dataHash"`
+	ReporterID            string    `json:"reporterId"`
+	FarmerID              string    `json:"farmerId"`
+	ClientID              string    `json:"clientId"`
+	ExchangeStart         time.Time `json:"exchangeStart"`
+	ExchangeEnd           time.Time `json:"exchangeEnd"`
+	ExchangeResultCode    int       `json:"exchangeResultCode"`
+	ExchangeResultMessage string    `json:"exchangeResultMessage"`
+	Created               time.Time `json:"created"`
+}
+
+// CreateExchangeReport saves a new report in the exchangereports collection
+func (c *Client) CreateExchangeReport(r ExchangeReport) (*ExchangeReport, error) {
+	defer c.observe("CreateExchangeReport")()
+
+	r.ID = uuid.New().String()
+	r.Created = time.Now().UTC()
+
+	return &r, c.exchangeReports.Insert(&r)
+}
+
+// ListClientDownloads returns the successful download reports for the client that ended within [start, end)
+func (c *Client) ListClientDownloads(client string, start, end time.Time) ([]ExchangeReport, error) {
+	defer c.observe("ListClientDownloads")()
+
+	reports := []ExchangeReport{}
+	err := c.exchangeReports.Find(bson.M{
+		"clientid":              client,
+		"exchangeresultcode":    ExchangeSuccess,
+		"exchangeresultmessage": ShardDownloaded,
+		"exchangeend":           bson.M{"$gte": start, "$lt": end},
+	}).All(&reports)
+
+	return reports, err
+}
+
+// ListClientReports returns every exchange report where the user with the provided ID is the client
+func (c *Client) ListClientReports(client string) ([]ExchangeReport, error) {
+	defer c.observe("ListClientReports")()
+
+	reports := []ExchangeReport{}
+	err := c.exchangeReports.Find(bson.M{"clientid": client}).All(&reports)
+
+	return reports, err
+}
@@ -0,0 +1,61 @@
+package mongodb
+
+import "time"
+
+// UserExport contains everything stored about a user
+type UserExport struct {
+	Generated       time.Time        `json:"generated"`
+	User            User             `json:"user"`
+	Usage           Usage            `json:"usage"`
+	PublicKeys      []PublicKey      `json:"publicKeys"`
+	Buckets         []Bucket         `json:"buckets"`
+	Files           []BucketEntry    `json:"files"`
+	Frames          []Frame          `json:"frames"`
+	ExchangeReports []ExchangeReport `json:"exchangeReports"`
+}
+
+// ExportUser collects every document referencing the user with the provided ID. The password
+// hash is omitted since it is a credential rather than data about the user.
+func (c *Client) ExportUser(id string) (*UserExport, error) {
+	defer c.observe("ExportUser")()
+	db := c.unobserved()
+
+	u, err := db.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &UserExport{Generated: time.Now().UTC(), User: *u}
+	e.User.Hashpass, e.User.HashAlgorithm = "", ""
+	e.Usage = Usage{
+		BytesUploaded:   u.BytesUploaded.Rolled(e.Generated),
+		BytesDownloaded: u.BytesDownloaded.Rolled(e.Generated),
+	}
+
+	if e.PublicKeys, err = db.ListPublicKeys(id); err != nil {
+		return nil, err
+	}
+
+	if e.Buckets, err = db.ListBuckets(id); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]string, 0, len(e.Buckets))
+	for _, b := range e.Buckets {
+		buckets = append(buckets, b.ID)
+	}
+
+	if e.Files, err = db.ListBucketEntries(buckets); err != nil {
+		return nil, err
+	}
+
+	if e.Frames, err = db.ListFrames(id); err != nil {
+		return nil, err
+	}
+
+	if e.ExchangeReports, err = db.ListClientReports(id); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -1,13 +1,75 @@
 package mongodb
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
 
 // Frame defines the frame schema in the frames collection
 type Frame struct {
-	ID      string    `json:"_id"`
+	ID      string    `bson:"_id" json:"_id"`
 	User    string    `json:"user"`
 	Shards  []string  `json:"shards"`
 	Size    int       `json:"size"`
 	Locked  bool      `json:"locked"`
 	Created time.Time `json:"created"`
 }
+
+// GetFrame queries for a frame by its ID
+func (c *Client) GetFrame(id string) (*Frame, error) {
+	defer c.observe("GetFrame")()
+
+	f := &Frame{}
+	err := c.frames.Find(bson.M{"_id": id}).One(f)
+	return f, err
+}
+
+// CreateFrame initializes and saves a new empty frame for the user with the provided ID
+func (c *Client) CreateFrame(user string) (*Frame, error) {
+	defer c.observe("CreateFrame")()
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	f := &Frame{
+		ID:      hex.EncodeToString(b),
+		User:    user,
+		Shards:  []string{},
+		Created: time.Now().UTC(),
+	}
+
+	return f, c.frames.Insert(f)
+}
+
+// ListFrames returns all frames owned by the user with the provided ID
+func (c *Client) ListFrames(user string) ([]Frame, error) {
+	defer c.observe("ListFrames")()
+
+	frames := []Frame{}
+	err := c.frames.Find(bson.M{"user": user}).All(&frames)
+	return frames, err
+}
+
+// GetStoredBytes returns the total size of the frames owned by the user with the provided ID
+func (c *Client) GetStoredBytes(user string) (int64, error) {
+	defer c.observe("GetStoredBytes")()
+
+	total := struct {
+		Bytes int64 `bson:"bytes"`
+	}{}
+	err := c.frames.Pipe([]bson.M{
+		{"$match": bson.M{"user": user}},
+		{"$group": bson.M{"_id": nil, "bytes": bson.M{"$sum": "$size"}}},
+	}).One(&total)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	}
+
+	return total.Bytes, err
+}
@@ -0,0 +1,190 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+
+	"github.com/coyle/bridge/storage/password"
+)
+
+// userReferences lists every collection field that stores a user ID
+var userReferences = []struct {
+	collection func(c *Client) *mgo.Collection
+	field      string
+}{
+	{func(c *Client) *mgo.Collection { return c.publicKeys }, "user"},
+	{func(c *Client) *mgo.Collection { return c.buckets }, "user"},
+	{func(c *Client) *mgo.Collection { return c.frames }, "user"},
+	{func(c *Client) *mgo.Collection { return c.userTokens }, "user"},
+	{func(c *Client) *mgo.Collection { return c.debits }, "user"},
+	{func(c *Client) *mgo.Collection { return c.exchangeReports }, "clientid"},
+}
+
+// MigrateUserIDs re-keys users created when the _id was their email address so that the _id
+// is their UUID and the address is stored in the email field. References to the old ID in
+// other collections are updated. It is safe to run repeatedly and to resume after a failure.
+func (c *Client) MigrateUserIDs() (int, error) {
+	defer c.observe("MigrateUserIDs")()
+
+	if err := c.users.EnsureIndex(mgo.Index{Key: []string{"email"}, Unique: true, Sparse: true}); err != nil {
+		return 0, err
+	}
+
+	legacy := []User{}
+	if err := c.users.Find(bson.M{"email": bson.M{"$exists": false}}).All(&legacy); err != nil {
+		return 0, err
+	}
+
+	for i, u := range legacy {
+		if err := c.migrateUserID(u); err != nil {
+			return i, err
+		}
+	}
+
+	return len(legacy), nil
+}
+
+func (c *Client) migrateUserID(u User) error {
+	oldID := u.ID
+
+	if u.UUID == "" {
+		u.UUID = uuid.New().String()
+	}
+	u.ID = u.UUID
+	u.Email = oldID
+
+	if u.ID == oldID {
+		return c.users.UpdateId(oldID, bson.M{"$set": bson.M{"email": oldID}})
+	}
+
+	// the old document is only removed once everything points at the new one, so a failed
+	// migration leaves it in place to be picked up again on the next run
+	if _, err := c.users.UpsertId(u.ID, &u); err != nil {
+		return err
+	}
+
+	for _, ref := range userReferences {
+		if _, err := ref.collection(c).UpdateAll(bson.M{ref.field: oldID}, bson.M{"$set": bson.M{ref.field: u.ID}}); err != nil {
+			return err
+		}
+	}
+
+	return c.users.RemoveId(oldID)
+}
+
+// MigrateLegacyPasswords wraps the password values the original bridge stored as received in
+// a salted hash, so those rows no longer hold a working credential. Users keep signing in with
+// the same credential and are rehashed on their first login. It is safe to run repeatedly and
+// to resume after a failure.
+func (c *Client) MigrateLegacyPasswords() (int, error) {
+	defer c.observe("MigrateLegacyPasswords")()
+
+	legacy := bson.M{"hashpass": bson.M{"$nin": []interface{}{nil, ""}}, "hashalgorithm": bson.M{"$in": []interface{}{nil, password.AlgorithmLegacy}}}
+	users := []User{}
+	if err := c.users.Find(legacy).Select(bson.M{"hashpass": 1}).All(&users); err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, u := range users {
+		hash, algorithm, err := password.Wrap(u.Hashpass)
+		if err != nil {
+			return migrated, err
+		}
+
+		err = c.users.Update(
+			bson.M{"_id": u.ID, "hashpass": u.Hashpass, "hashalgorithm": legacy["hashalgorithm"]},
+			bson.M{"$set": bson.M{"hashpass": hash, "hashalgorithm": algorithm}},
+		)
+		if err == mgo.ErrNotFound {
+			// The password was changed since it was read
+			continue
+		}
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// MigratePartnerRevShare renames the revshare field written before partners were stored under
+// the legacy bridge's revShareTotalPercentage. It is safe to run repeatedly.
+func (c *Client) MigratePartnerRevShare() (int, error) {
+	defer c.observe("MigratePartnerRevShare")()
+
+	info, err := c.partners.UpdateAll(
+		bson.M{"revshare": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"revshare": "revShareTotalPercentage"}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Updated, nil
+}
+
+// legacyTokens are the user fields that held one-time tokens before the usertokens collection,
+// with the purpose each served
+var legacyTokens = []struct {
+	field   string
+	purpose string
+}{
+	{"activator", PurposeActivation},
+	{"deactivator", PurposeDeactivation},
+	{"resetter", PurposeReset},
+}
+
+// MigrateLegacyTokens moves tokens stored on users into the usertokens collection, so that
+// links emailed before the move keep working. The legacy tokens have no expiry, so each is
+// given the full lifetime of its purpose from the time it is migrated. It is safe to run
+// repeatedly and to resume after a failure.
+func (c *Client) MigrateLegacyTokens() (int, error) {
+	defer c.observe("MigrateLegacyTokens")()
+
+	migrated := 0
+	for _, l := range legacyTokens {
+		users := []bson.M{}
+		err := c.users.Find(bson.M{l.field: bson.M{"$nin": []interface{}{nil, ""}}}).Select(bson.M{l.field: 1}).All(&users)
+		if err != nil {
+			return migrated, err
+		}
+
+		for _, u := range users {
+			id, _ := u["_id"].(string)
+			token, _ := u[l.field].(string)
+			if err := c.migrateLegacyToken(id, l.field, l.purpose, token); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+func (c *Client) migrateLegacyToken(user, field, purpose, token string) error {
+	now := time.Now().UTC()
+	err := c.userTokens.Insert(&UserToken{
+		ID:      hashToken(token),
+		User:    user,
+		Purpose: purpose,
+		Created: now,
+		Expires: now.Add(userTokenTTLs[purpose]),
+	})
+	if err != nil && !mgo.IsDup(err) {
+		return err
+	}
+
+	err = c.users.Update(bson.M{"_id": user, field: token}, bson.M{"$unset": bson.M{field: ""}})
+	if err == mgo.ErrNotFound {
+		// The token was used or replaced since it was read
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/coyle/bridge/storage/password"
+)
+
+func TestMigrateLegacyPasswordsKeepsBaselineLogins(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	// a row as the original bridge wrote it: the hex SHA-256 sent by the client, stored as-is
+	// with no algorithm tag
+	id := uuid.New().String()
+	email := id + "@storj.io"
+	credential := fmt.Sprintf("%x", sha256.Sum256([]byte("password")))
+	assert.NoError(t, c.users.Insert(bson.M{"_id": id, "uuid": id, "email": email, "hashpass": credential, "activated": true}))
+
+	_, err := c.AuthenticateUser(email, credential)
+	assert.Equal(t, password.ErrUnwrapped, err, "unmigrated rows are never compared")
+
+	_, err = c.MigrateLegacyPasswords()
+	assert.NoError(t, err)
+
+	u, err := c.GetUser(id)
+	assert.NoError(t, err)
+	assert.Equal(t, password.AlgorithmBcryptLegacy, u.HashAlgorithm)
+	assert.NotEqual(t, credential, u.Hashpass)
+
+	_, err = c.AuthenticateUser(email, credential)
+	assert.NoError(t, err)
+
+	u, err = c.GetUser(id)
+	assert.NoError(t, err)
+	assert.Equal(t, password.AlgorithmBcrypt, u.HashAlgorithm, "rehashed on first login")
+
+	_, err = c.AuthenticateUser(email, credential)
+	assert.NoError(t, err)
+	_, err = c.AuthenticateUser(email, "password")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
@@ -1,15 +1,91 @@
 package mongodb
 
 import (
+	"time"
+
 	"github.com/coyle/bridge/storage"
 	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
 )
 
 // GetPartner searches for a partner with the provided name from the partners collection
 func (c *Client) GetPartner(name string) (*storage.Partner, error) {
+	defer c.observe("GetPartner")()
+
 	p := &storage.Partner{}
 	err := c.partners.Find(bson.M{"name": name}).One(p)
 
 	return p, err
 
 }
+
+// GetPartnerByID queries for a partner by their ID
+func (c *Client) GetPartnerByID(id string) (*storage.Partner, error) {
+	defer c.observe("GetPartnerByID")()
+
+	p := &storage.Partner{}
+	err := c.partners.FindId(id).One(p)
+
+	return p, err
+}
+
+// ListPartners returns all partners ordered by name
+func (c *Client) ListPartners() ([]storage.Partner, error) {
+	defer c.observe("ListPartners")()
+
+	partners := []storage.Partner{}
+	err := c.partners.Find(nil).Sort("name").All(&partners)
+
+	return partners, err
+}
+
+// CreatePartner validates and saves a new partner in the partners collection
+func (c *Client) CreatePartner(p storage.Partner) (*storage.Partner, error) {
+	defer c.observe("CreatePartner")()
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	cnt, err := c.partners.Find(bson.M{"name": p.Name}).Count()
+	if err != nil {
+		return nil, err
+	}
+	if cnt > 0 {
+		return nil, storage.ErrPartnerExists
+	}
+
+	p.ID = uuid.New().String()
+	p.Created = time.Now().UTC()
+
+	return &p, c.partners.Insert(&p)
+}
+
+// UpdatePartner validates and replaces the name and revenue share of an existing partner
+func (c *Client) UpdatePartner(p storage.Partner) error {
+	defer c.observe("UpdatePartner")()
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	cnt, err := c.partners.Find(bson.M{"name": p.Name, "_id": bson.M{"$ne": p.ID}}).Count()
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return storage.ErrPartnerExists
+	}
+
+	return c.partners.UpdateId(p.ID, bson.M{"$set": bson.M{"name": p.Name, "revShareTotalPercentage": p.RevShare}})
+}
+
+// ListReferredUsers returns all users referred by the partner with the provided ID
+func (c *Client) ListReferredUsers(partner string) ([]User, error) {
+	defer c.observe("ListReferredUsers")()
+
+	users := []User{}
+	err := c.users.Find(bson.M{"referralpartner": partner}).All(&users)
+
+	return users, err
+}
@@ -0,0 +1,136 @@
+package mongodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	// ErrProcessorExists is returned when a user already has a payment processor with the same name
+	ErrProcessorExists = errors.New("payment processor already exists")
+	// ErrProcessorNotFound is returned when a user has no payment processor with the provided name
+	ErrProcessorNotFound = errors.New("payment processor not found")
+)
+
+// PaymentProcessor defines the payment processor sub-document stored on a user. The data the
+// client registered with is only passed to the processor and never stored.
+type PaymentProcessor struct {
+	Name        string    `json:"name"`
+	Default     bool      `json:"default"`
+	Customer    string    `json:"customer,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// Redacted returns a copy of the processor without the processor's customer reference
+func (p PaymentProcessor) Redacted() PaymentProcessor {
+	return PaymentProcessor{
+		Name:        p.Name,
+		Default:     p.Default,
+		Description: p.Description,
+		Created:     p.Created,
+	}
+}
+
+// AddPaymentProcessor saves a new payment processor on the user. The first processor added
+// becomes the default.
+func (c *Client) AddPaymentProcessor(id string, p PaymentProcessor) error {
+	defer c.observe("AddPaymentProcessor")()
+
+	u, err := c.unobserved().GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	if findProcessor(u.PaymentProcessors, p.Name) >= 0 {
+		return ErrProcessorExists
+	}
+
+	p.Default = len(u.PaymentProcessors) == 0
+	if p.Created.IsZero() {
+		p.Created = time.Now().UTC()
+	}
+
+	err = c.users.Update(
+		bson.M{"_id": id, "paymentprocessors.name": bson.M{"$ne": p.Name}},
+		bson.M{"$push": bson.M{"paymentprocessors": p}},
+	)
+	if err == mgo.ErrNotFound {
+		// The processor was added by a concurrent request since the user was read
+		return ErrProcessorExists
+	}
+
+	return err
+}
+
+// RemovePaymentProcessor deletes the named payment processor from the user. If it was the
+// default the first remaining processor becomes the default.
+func (c *Client) RemovePaymentProcessor(id, name string) error {
+	defer c.observe("RemovePaymentProcessor")()
+
+	u, err := c.unobserved().GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	i := findProcessor(u.PaymentProcessors, name)
+	if i < 0 {
+		return ErrProcessorNotFound
+	}
+
+	wasDefault := u.PaymentProcessors[i].Default
+	if err := c.users.UpdateId(id, bson.M{"$pull": bson.M{"paymentprocessors": bson.M{"name": name}}}); err != nil {
+		return err
+	}
+
+	remaining := append(u.PaymentProcessors[:i:i], u.PaymentProcessors[i+1:]...)
+	if !wasDefault || len(remaining) == 0 {
+		return nil
+	}
+
+	return c.unobserved().SetDefaultPaymentProcessor(id, remaining[0].Name)
+}
+
+// SetDefaultPaymentProcessor marks the named payment processor as the user's default
+func (c *Client) SetDefaultPaymentProcessor(id, name string) error {
+	defer c.observe("SetDefaultPaymentProcessor")()
+
+	u, err := c.unobserved().GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	if findProcessor(u.PaymentProcessors, name) < 0 {
+		return ErrProcessorNotFound
+	}
+
+	for i := range u.PaymentProcessors {
+		u.PaymentProcessors[i].Default = u.PaymentProcessors[i].Name == name
+	}
+
+	return c.users.UpdateId(id, bson.M{"$set": bson.M{"paymentprocessors": u.PaymentProcessors}})
+}
+
+// DefaultPaymentProcessor returns the user's default payment processor or nil if they have none
+func (u *User) DefaultPaymentProcessor() *PaymentProcessor {
+	for i := range u.PaymentProcessors {
+		if u.PaymentProcessors[i].Default {
+			return &u.PaymentProcessors[i]
+		}
+	}
+
+	return nil
+}
+
+func findProcessor(processors []PaymentProcessor, name string) int {
+	for i, p := range processors {
+		if p.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
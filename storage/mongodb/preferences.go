@@ -0,0 +1,103 @@
+package mongodb
+
+import (
+	"errors"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	// ErrInvalidPreferences is returned when a preferences update contains out of range values
+	ErrInvalidPreferences = errors.New("invalid preferences")
+)
+
+// Preferences contains all user preferences
+type Preferences struct {
+	DNT           bool                    `json:"dnt"`
+	Notifications NotificationPreferences `json:"notifications"`
+	Buckets       BucketPreferences       `json:"buckets"`
+}
+
+// NotificationPreferences contains the optional emails a user has opted in to
+type NotificationPreferences struct {
+	Billing bool `json:"billing"`
+	Product bool `json:"product"`
+}
+
+// BucketPreferences contains the settings applied to newly created buckets
+type BucketPreferences struct {
+	Storage  int `json:"storage"`
+	Transfer int `json:"transfer"`
+}
+
+// PreferencesUpdate contains the preferences to change. Nil fields are left unchanged.
+type PreferencesUpdate struct {
+	DNT           *bool `json:"dnt"`
+	Notifications *struct {
+		Billing *bool `json:"billing"`
+		Product *bool `json:"product"`
+	} `json:"notifications"`
+	Buckets *struct {
+		Storage  *int `json:"storage"`
+		Transfer *int `json:"transfer"`
+	} `json:"buckets"`
+}
+
+// fields returns the validated update as a set of dotted field paths
+func (p PreferencesUpdate) fields() (bson.M, error) {
+	set := bson.M{}
+
+	if p.DNT != nil {
+		set["preferences.dnt"] = *p.DNT
+	}
+
+	if n := p.Notifications; n != nil {
+		if n.Billing != nil {
+			set["preferences.notifications.billing"] = *n.Billing
+		}
+		if n.Product != nil {
+			set["preferences.notifications.product"] = *n.Product
+		}
+	}
+
+	if b := p.Buckets; b != nil {
+		if b.Storage != nil {
+			if *b.Storage < 0 {
+				return nil, ErrInvalidPreferences
+			}
+			set["preferences.buckets.storage"] = *b.Storage
+		}
+		if b.Transfer != nil {
+			if *b.Transfer < 0 {
+				return nil, ErrInvalidPreferences
+			}
+			set["preferences.buckets.transfer"] = *b.Transfer
+		}
+	}
+
+	return set, nil
+}
+
+// UpdatePreferences validates and applies a partial update to the user's preferences and
+// returns the resulting preferences
+func (c *Client) UpdatePreferences(id string, p PreferencesUpdate) (*Preferences, error) {
+	defer c.observe("UpdatePreferences")()
+
+	set, err := p.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(set) > 0 {
+		if err := c.users.UpdateId(id, bson.M{"$set": set}); err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := c.unobserved().GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &u.Preferences, nil
+}
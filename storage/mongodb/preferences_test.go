@@ -0,0 +1,50 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferencesUpdateFields(t *testing.T) {
+	cases := []struct {
+		name          string
+		body          string
+		expected      bson.M
+		expectedError error
+	}{
+		{
+			name:     "empty update",
+			body:     `{}`,
+			expected: bson.M{},
+		},
+		{
+			name:     "only provided fields are set",
+			body:     `{"dnt": true, "notifications": {"product": false}}`,
+			expected: bson.M{"preferences.dnt": true, "preferences.notifications.product": false},
+		},
+		{
+			name:     "bucket defaults",
+			body:     `{"buckets": {"storage": 10, "transfer": 0}}`,
+			expected: bson.M{"preferences.buckets.storage": 10, "preferences.buckets.transfer": 0},
+		},
+		{
+			name:          "negative bucket defaults are rejected",
+			body:          `{"buckets": {"storage": -1}}`,
+			expectedError: ErrInvalidPreferences,
+		},
+	}
+
+	for _, c := range cases {
+		p := PreferencesUpdate{}
+		assert.NoError(t, json.Unmarshal([]byte(c.body), &p), c.name)
+
+		fields, err := p.fields()
+		assert.Equal(t, c.expectedError, err, c.name)
+		if c.expectedError == nil {
+			assert.Equal(t, c.expected, fields, c.name)
+		}
+	}
+}
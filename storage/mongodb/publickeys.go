@@ -18,6 +18,8 @@ type PublicKey struct {
 
 // CreatePublicKey instantiates a new PublicKey for a user
 func (c *Client) CreatePublicKey(u *User, pubKey string) error {
+	defer c.observe("CreatePublicKey")()
+
 	pk, err := hex.DecodeString(pubKey)
 	if err != nil {
 		return err
@@ -28,7 +30,7 @@ func (c *Client) CreatePublicKey(u *User, pubKey string) error {
 	}
 
 	// if key exists return error
-	exists, err := c.PublicKeyExists(pubKey)
+	exists, err := c.unobserved().PublicKeyExists(pubKey)
 	if err != nil {
 		return err
 	}
@@ -48,6 +50,8 @@ func (c *Client) CreatePublicKey(u *User, pubKey string) error {
 
 // GetPublickey looks up a PublicKey document with the provided key
 func (c *Client) GetPublickey(key string) (*PublicKey, error) {
+	defer c.observe("GetPublickey")()
+
 	pk := &PublicKey{}
 	err := c.publicKeys.Find(bson.M{"_id": key}).One(pk)
 
@@ -56,6 +60,8 @@ func (c *Client) GetPublickey(key string) (*PublicKey, error) {
 
 // PublicKeyExists determines if the provided key exists in the publickeys collection
 func (c *Client) PublicKeyExists(key string) (bool, error) {
+	defer c.observe("PublicKeyExists")()
+
 	c.session.SetSafe(&mgo.Safe{})
 	cnt, err := c.publicKeys.FindId(key).Count()
 	if cnt > 0 {
@@ -64,3 +70,13 @@ func (c *Client) PublicKeyExists(key string) (bool, error) {
 
 	return false, err
 }
+
+// ListPublicKeys returns all public keys registered to the user with the provided ID
+func (c *Client) ListPublicKeys(user string) ([]PublicKey, error) {
+	defer c.observe("ListPublicKeys")()
+
+	keys := []PublicKey{}
+	err := c.publicKeys.Find(bson.M{"user": user}).All(&keys)
+
+	return keys, err
+}
@@ -0,0 +1,111 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// ListPurgeableUsers returns the users whose deactivation was confirmed before the provided time
+func (c *Client) ListPurgeableUsers(before time.Time) ([]User, error) {
+	defer c.observe("ListPurgeableUsers")()
+
+	users := []User{}
+	err := c.users.Find(bson.M{"deactivated": true, "deactivatedat": bson.M{"$lte": before}}).All(&users)
+	return users, err
+}
+
+// PurgeUser deletes the user's buckets and files, their frames and the shards only those frames
+// reference, their public keys and tokens, and finally the user document. It returns how
+// many documents were removed from each collection. Everything found through the buckets is
+// removed before the buckets, and the user document goes last, so a partial purge is retried
+// on the next run. The user document is kept if the user was activated again meanwhile.
+func (c *Client) PurgeUser(id string) (map[string]int, error) {
+	defer c.observe("PurgeUser")()
+
+	removed := map[string]int{}
+
+	buckets, err := c.unobserved().ListBuckets(id)
+	if err != nil {
+		return removed, err
+	}
+
+	bucketIDs := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		bucketIDs = append(bucketIDs, b.ID)
+	}
+
+	frames, err := c.unobserved().ListFrames(id)
+	if err != nil {
+		return removed, err
+	}
+
+	hashes := []string{}
+	for _, f := range frames {
+		hashes = append(hashes, f.Shards...)
+	}
+
+	// Shards are content addressed, so another user's frames may reference the same hash
+	orphans, err := c.orphanedShards(id, hashes)
+	if err != nil {
+		return removed, err
+	}
+
+	steps := []struct {
+		name   string
+		remove func() (int, error)
+	}{
+		{"bucketentries", func() (int, error) {
+			return removeAll(c.bucketEntries.RemoveAll(bson.M{"bucket": bson.M{"$in": bucketIDs}}))
+		}},
+		{"tokens", func() (int, error) { return removeAll(c.tokens.RemoveAll(bson.M{"bucket": bson.M{"$in": bucketIDs}})) }},
+		{"buckets", func() (int, error) { return removeAll(c.buckets.RemoveAll(bson.M{"user": id})) }},
+		{"shards", func() (int, error) { return removeAll(c.shards.RemoveAll(bson.M{"_id": bson.M{"$in": orphans}})) }},
+		{"frames", func() (int, error) { return removeAll(c.frames.RemoveAll(bson.M{"user": id})) }},
+		{"publickeys", func() (int, error) { return removeAll(c.publicKeys.RemoveAll(bson.M{"user": id})) }},
+		{"usertokens", func() (int, error) { return removeAll(c.userTokens.RemoveAll(bson.M{"user": id})) }},
+		{"users", func() (int, error) { return removeAll(c.users.RemoveAll(bson.M{"_id": id, "deactivated": true})) }},
+	}
+
+	for _, s := range steps {
+		n, err := s.remove()
+		if err != nil {
+			return removed, err
+		}
+		removed[s.name] = n
+	}
+
+	return removed, nil
+}
+
+// orphanedShards returns the hashes that no frame of another user references
+func (c *Client) orphanedShards(user string, hashes []string) ([]string, error) {
+	shared := []string{}
+	err := c.frames.Find(bson.M{"shards": bson.M{"$in": hashes}, "user": bson.M{"$ne": user}}).Distinct("shards", &shared)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+	for _, h := range shared {
+		referenced[h] = true
+	}
+
+	orphans := []string{}
+	for _, h := range hashes {
+		if !referenced[h] {
+			orphans = append(orphans, h)
+		}
+	}
+
+	return orphans, nil
+}
+
+func removeAll(info *mgo.ChangeInfo, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
@@ -0,0 +1,85 @@
+package mongodb
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient connects to the database named by MONGO, skipping the test when it is not set
+func testClient(t *testing.T) *Client {
+	if os.Getenv("MONGO") == "" {
+		t.Skip("MONGO is not set")
+	}
+
+	c, err := NewClient(os.Getenv("MONGO"), DefaultDatabase)
+	if err != nil {
+		t.Fatalf("unable to connect to Mongo: %s", err)
+	}
+
+	return c
+}
+
+func TestPurgeUserKeepsSharedShards(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	purged, other := TestUser(true), TestUser(true)
+	for _, u := range []*User{purged, other} {
+		_, err := c.CreateUser(*u)
+		assert.NoError(t, err)
+	}
+
+	shared, owned := purged.ID+"-shared", purged.ID+"-owned"
+	assert.NoError(t, c.shards.Insert(Shard{ID: shared}, Shard{ID: owned}))
+	assert.NoError(t, c.frames.Insert(
+		Frame{ID: purged.ID, User: purged.ID, Shards: []string{shared, owned}, Created: time.Now().UTC()},
+		Frame{ID: other.ID, User: other.ID, Shards: []string{shared}, Created: time.Now().UTC()},
+	))
+
+	removed, err := c.PurgeUser(purged.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed["shards"])
+	assert.Equal(t, 1, removed["frames"])
+
+	shards, err := c.GetShards([]string{shared, owned})
+	assert.NoError(t, err)
+	if assert.Len(t, shards, 1) {
+		assert.Equal(t, shared, shards[0].ID)
+	}
+
+	_, err = c.GetFrame(other.ID)
+	assert.NoError(t, err)
+}
+
+func TestReactivatedUserNotPurged(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.ConfirmUserDeactivation(u.ID))
+	assert.NoError(t, c.ActivateUser(u.ID))
+
+	users, err := c.ListPurgeableUsers(time.Now().UTC().Add(time.Hour))
+	assert.NoError(t, err)
+	for _, p := range users {
+		assert.NotEqual(t, u.ID, p.ID)
+	}
+
+	activated, err := c.GetUser(u.ID)
+	assert.NoError(t, err)
+	assert.False(t, activated.Deactivated)
+	assert.True(t, activated.DeactivatedAt.IsZero())
+
+	// a purge that listed the user before they were activated again keeps the account
+	removed, err := c.PurgeUser(u.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed["users"])
+	_, err = c.GetUser(u.ID)
+	assert.NoError(t, err)
+}
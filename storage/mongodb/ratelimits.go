@@ -0,0 +1,73 @@
+package mongodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// maxRateLimitRetries bounds the compare-and-swap loop when a bucket is taken from concurrently
+const maxRateLimitRetries = 10
+
+// ErrRateLimitConflict is returned when a rate limit bucket could not be updated due to concurrent writers
+var ErrRateLimitConflict = errors.New("rate limit changed concurrently")
+
+// RateLimit defines the token bucket schema in the ratelimits collection
+type RateLimit struct {
+	ID      string    `bson:"_id" json:"id"`
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+	Expires time.Time `json:"expires"`
+}
+
+// EnsureRateLimitIndex removes rate limit buckets once they expire
+func (c *Client) EnsureRateLimitIndex() error {
+	defer c.observe("EnsureRateLimitIndex")()
+
+	return c.rateLimits.EnsureIndex(mgo.Index{Key: []string{"expires"}, ExpireAfter: time.Second})
+}
+
+// UpdateRateLimit applies update to the bucket with the provided key. update receives the
+// stored tokens and update time, which are zero for a new bucket, and returns the new
+// values and when the bucket may be forgotten. The write only applies if the bucket is
+// unchanged since it was read.
+func (c *Client) UpdateRateLimit(key string, update func(tokens float64, updated time.Time) (float64, time.Time, time.Time)) error {
+	defer c.observe("UpdateRateLimit")()
+
+	for i := 0; i < maxRateLimitRetries; i++ {
+		current := RateLimit{}
+		err := c.rateLimits.FindId(key).One(&current)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		exists := err == nil
+
+		tokens, updated, expires := update(current.Tokens, current.Updated)
+		// mongo stores milliseconds, so truncate to keep the next compare exact
+		next := RateLimit{
+			ID:      key,
+			Tokens:  tokens,
+			Updated: updated.UTC().Truncate(time.Millisecond),
+			Expires: expires.UTC(),
+		}
+
+		if !exists {
+			err = c.rateLimits.Insert(&next)
+			if mgo.IsDup(err) {
+				continue
+			}
+			return err
+		}
+
+		err = c.rateLimits.Update(bson.M{"_id": key, "tokens": current.Tokens, "updated": current.Updated}, &next)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+
+		return err
+	}
+
+	return ErrRateLimitConflict
+}
@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// Shard defines the shard schema in the shards collection
+type Shard struct {
+	ID        string     `bson:"_id" json:"hash"`
+	Size      int64      `json:"size"`
+	Contracts []Contract `json:"contracts"`
+}
+
+// Contract defines the storage contract sub-document between a farmer and the bridge for a shard
+type Contract struct {
+	Farmer     string    `json:"farmer"`
+	StoreBegin time.Time `json:"storeBegin"`
+	StoreEnd   time.Time `json:"storeEnd"`
+}
+
+// StoredSpan returns the earliest start and latest end of the shard's contracts
+func (s *Shard) StoredSpan() (time.Time, time.Time) {
+	var begin, end time.Time
+	for _, c := range s.Contracts {
+		if begin.IsZero() || c.StoreBegin.Before(begin) {
+			begin = c.StoreBegin
+		}
+		if c.StoreEnd.After(end) {
+			end = c.StoreEnd
+		}
+	}
+
+	return begin, end
+}
+
+// GetShards queries for the shards with the provided hashes
+func (c *Client) GetShards(hashes []string) ([]Shard, error) {
+	defer c.observe("GetShards")()
+
+	shards := []Shard{}
+	err := c.shards.Find(bson.M{"_id": bson.M{"$in": hashes}}).All(&shards)
+	return shards, err
+}
+
+// ListUserShards returns every shard referenced by the user's frames
+func (c *Client) ListUserShards(user string) ([]Shard, error) {
+	defer c.observe("ListUserShards")()
+
+	frames, err := c.unobserved().ListFrames(user)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []string{}
+	for _, f := range frames {
+		hashes = append(hashes, f.Shards...)
+	}
+
+	return c.unobserved().GetShards(hashes)
+}
@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Stats contains totals across the whole bridge
+type Stats struct {
+	Users          int   `json:"users"`
+	Buckets        int   `json:"buckets"`
+	StoredBytes    int64 `json:"storedBytes"`
+	OnlineContacts int   `json:"onlineContacts"`
+}
+
+// storedBytesID is the stats document holding the last total of frame sizes
+const storedBytesID = "storedBytes"
+
+// GetStats counts users and buckets, the storage nodes seen since onlineSince, and the total
+// size of every frame. Summing frames scans the collection, so the total is shared through
+// the stats collection and recomputed by one instance once it is older than maxAge.
+func (c *Client) GetStats(onlineSince time.Time, maxAge time.Duration) (*Stats, error) {
+	defer c.observe("GetStats")()
+
+	var (
+		s   Stats
+		err error
+	)
+
+	if s.Users, err = c.users.Count(); err != nil {
+		return nil, err
+	}
+
+	if s.Buckets, err = c.buckets.Count(); err != nil {
+		return nil, err
+	}
+
+	if s.OnlineContacts, err = c.unobserved().CountOnlineContacts(onlineSince); err != nil {
+		return nil, err
+	}
+
+	if s.StoredBytes, err = c.storedBytes(maxAge); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// storedBytes returns the total size of every frame. The instance that claims a total older
+// than maxAge recomputes it; the others return the last total while it does.
+func (c *Client) storedBytes(maxAge time.Duration) (int64, error) {
+	total := struct {
+		Bytes int64 `bson:"bytes"`
+	}{}
+
+	now := time.Now().UTC()
+	_, err := c.stats.Upsert(
+		bson.M{"_id": storedBytesID, "computed": bson.M{"$lte": now.Add(-maxAge)}},
+		bson.M{"$set": bson.M{"computed": now}},
+	)
+	if mgo.IsDup(err) {
+		err = c.stats.FindId(storedBytesID).One(&total)
+		return total.Bytes, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = c.frames.Pipe([]bson.M{{"$group": bson.M{"_id": nil, "bytes": bson.M{"$sum": "$size"}}}}).One(&total)
+	if err != nil && err != mgo.ErrNotFound {
+		return 0, err
+	}
+
+	return total.Bytes, c.stats.UpdateId(storedBytesID, bson.M{"$set": bson.M{"bytes": total.Bytes}})
+}
@@ -0,0 +1,30 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatsSharesStoredBytes(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	c.stats.RemoveId(storedBytesID)
+	before, err := c.GetStats(time.Now(), time.Hour)
+	assert.NoError(t, err)
+
+	frame := Frame{ID: uuid.New().String(), User: uuid.New().String(), Size: 100}
+	assert.NoError(t, c.frames.Insert(frame))
+	defer c.frames.RemoveId(frame.ID)
+
+	cached, err := c.GetStats(time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, before.StoredBytes, cached.StoredBytes, "a fresh total is reused")
+
+	recomputed, err := c.GetStats(time.Now(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, before.StoredBytes+100, recomputed.StoredBytes, "a stale total is recomputed")
+}
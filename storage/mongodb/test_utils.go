@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
 )
 
@@ -12,7 +13,8 @@ import (
 func TestUser(activated bool) *User {
 	uid := uuid.New().String()
 	u := User{
-		ID:              fmt.Sprintf("%s@storj.io", uid),
+		ID:              uid,
+		Email:           fmt.Sprintf("%s@storj.io", uid),
 		UUID:            uid,
 		Hashpass:        fmt.Sprintf("%x", sha256.Sum256([]byte("password"))),
 		Activated:       activated,
@@ -28,3 +30,10 @@ func TestUser(activated bool) *User {
 
 	return &u
 }
+
+// TestUserTokens returns the unexpired one-time tokens issued to a user for the purpose
+func (c *Client) TestUserTokens(user, purpose string) ([]UserToken, error) {
+	tokens := []UserToken{}
+	err := c.userTokens.Find(bson.M{"user": user, "purpose": purpose, "expires": bson.M{"$gt": time.Now().UTC()}}).All(&tokens)
+	return tokens, err
+}
@@ -0,0 +1,53 @@
+package mongodb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// OperationPush is the token operation for uploading shards
+	OperationPush = "PUSH"
+	// OperationPull is the token operation for downloading shards
+	OperationPull = "PULL"
+
+	tokenTTL = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidOperation is returned when a token is requested for an unknown operation
+	ErrInvalidOperation = errors.New("invalid token operation")
+)
+
+// Token defines the bucket operation token schema in the tokens collection
+type Token struct {
+	ID        string    `bson:"_id" json:"token"`
+	Bucket    string    `json:"bucket"`
+	Operation string    `json:"operation"`
+	Expires   time.Time `json:"expires"`
+}
+
+// CreateToken generates a new token authorizing the operation on the bucket
+func (c *Client) CreateToken(bucket, operation string) (*Token, error) {
+	defer c.observe("CreateToken")()
+
+	if operation != OperationPush && operation != OperationPull {
+		return nil, ErrInvalidOperation
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	t := &Token{
+		ID:        hex.EncodeToString(b),
+		Bucket:    bucket,
+		Operation: operation,
+		Expires:   time.Now().UTC().Add(tokenTTL),
+	}
+
+	return t, c.tokens.Insert(t)
+}
@@ -0,0 +1,131 @@
+package mongodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	// ErrTOTPEnabled is returned when enrolling a user who already has two-factor enabled
+	ErrTOTPEnabled = errors.New("two-factor authentication already enabled")
+	// ErrTOTPNotEnrolled is returned when enabling two-factor before a secret was generated
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication not enrolled")
+	// ErrInvalidRecoveryCode is returned when a recovery code is unknown or already used
+	ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+	// ErrTOTPReplayed is returned when a TOTP code is for a time step that was already used
+	ErrTOTPReplayed = errors.New("two-factor code already used")
+	// ErrTOTPLocked is returned when a user has made too many recent two-factor attempts
+	ErrTOTPLocked = errors.New("too many two-factor attempts")
+)
+
+// SetTOTPSecret stores a pending TOTP secret for the user. It only takes effect once
+// EnableTOTP is called, and can not replace the secret of an enabled user.
+func (c *Client) SetTOTPSecret(id, secret string) error {
+	defer c.observe("SetTOTPSecret")()
+
+	err := c.users.Update(
+		bson.M{"_id": id, "totpenabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"totpsecret": secret}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrTOTPEnabled
+	}
+
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication for the user and replaces their recovery
+// codes. Only hashes of the codes are stored. step is the time step of the code that confirmed
+// enrollment, which is not accepted again.
+func (c *Client) EnableTOTP(id string, recoveryCodes []string, step int64) error {
+	defer c.observe("EnableTOTP")()
+
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = hashToken(code)
+	}
+
+	err := c.users.Update(
+		bson.M{"_id": id, "totpsecret": bson.M{"$nin": []interface{}{nil, ""}}},
+		bson.M{"$set": bson.M{"totpenabled": true, "recoverycodes": hashes, "totplaststep": step}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrTOTPNotEnrolled
+	}
+
+	return err
+}
+
+// DisableTOTP turns off two-factor authentication and discards the secret and recovery codes
+func (c *Client) DisableTOTP(id string) error {
+	defer c.observe("DisableTOTP")()
+
+	return c.users.UpdateId(id, bson.M{
+		"$set":   bson.M{"totpenabled": false},
+		"$unset": bson.M{"totpsecret": "", "recoverycodes": "", "totplaststep": "", "totpattempts": "", "totpattemptedat": ""},
+	})
+}
+
+// TakeTOTPAttempt counts a second factor attempt for the user, returning ErrTOTPLocked once
+// max attempts have been made. Attempts are forgotten window after the last one, and when a
+// code is accepted.
+func (c *Client) TakeTOTPAttempt(id string, max int, window time.Duration) error {
+	defer c.observe("TakeTOTPAttempt")()
+
+	now := time.Now().UTC()
+	_, err := c.users.UpdateAll(
+		bson.M{"_id": id, "totpattemptedat": bson.M{"$lte": now.Add(-window)}},
+		bson.M{"$set": bson.M{"totpattempts": 0}},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = c.users.Update(
+		bson.M{"_id": id, "totpattempts": bson.M{"$not": bson.M{"$gte": max}}},
+		bson.M{"$inc": bson.M{"totpattempts": 1}, "$set": bson.M{"totpattemptedat": now}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrTOTPLocked
+	}
+
+	return err
+}
+
+// AcceptTOTPStep records the time step of an accepted TOTP code and forgets the user's
+// attempts. It returns ErrTOTPReplayed unless the step is later than the last accepted one,
+// so each code is only accepted once.
+func (c *Client) AcceptTOTPStep(id string, step int64) error {
+	defer c.observe("AcceptTOTPStep")()
+
+	err := c.users.Update(
+		bson.M{"_id": id, "totplaststep": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"totplaststep": step, "totpattempts": 0}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrTOTPReplayed
+	}
+
+	return err
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes and forgets their attempts. Each
+// code can only be used once.
+func (c *Client) UseRecoveryCode(id, code string) error {
+	defer c.observe("UseRecoveryCode")()
+
+	hash := hashToken(code)
+
+	err := c.users.Update(
+		bson.M{"_id": id, "recoverycodes": hash},
+		bson.M{"$pull": bson.M{"recoverycodes": hash}, "$set": bson.M{"totpattempts": 0}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrInvalidRecoveryCode
+	}
+
+	return err
+}
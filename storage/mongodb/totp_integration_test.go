@@ -0,0 +1,43 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeTOTPAttempt(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.TakeTOTPAttempt(u.ID, 3, time.Hour))
+	}
+	assert.Equal(t, ErrTOTPLocked, c.TakeTOTPAttempt(u.ID, 3, time.Hour))
+
+	assert.NoError(t, c.TakeTOTPAttempt(u.ID, 3, 0), "attempts are forgotten after the window")
+
+	assert.NoError(t, c.AcceptTOTPStep(u.ID, 100))
+	stored, err := c.GetUser(u.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stored.TOTPAttempts, "an accepted code forgets attempts")
+}
+
+func TestAcceptTOTPStepRejectsReplays(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.AcceptTOTPStep(u.ID, 100))
+	assert.Equal(t, ErrTOTPReplayed, c.AcceptTOTPStep(u.ID, 100))
+	assert.Equal(t, ErrTOTPReplayed, c.AcceptTOTPStep(u.ID, 99))
+	assert.NoError(t, c.AcceptTOTPStep(u.ID, 101))
+}
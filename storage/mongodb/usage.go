@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	bytesUploadedField   = "bytesuploaded"
+	bytesDownloadedField = "bytesdownloaded"
+
+	// maxUsageRetries bounds the compare-and-swap loop when counters are updated concurrently
+	maxUsageRetries = 10
+)
+
+var (
+	// ErrUsageConflict is returned when usage counters could not be updated due to concurrent writers
+	ErrUsageConflict = errors.New("usage counters changed concurrently")
+)
+
+// Usage contains the current transfer counters for a user
+type Usage struct {
+	BytesUploaded   BytesMeta `json:"bytesUploaded"`
+	BytesDownloaded BytesMeta `json:"bytesDownloaded"`
+}
+
+// Rolled returns a copy of the counters with every window that has expired by now reset
+func (b BytesMeta) Rolled(now time.Time) BytesMeta {
+	if now.Sub(b.LastHourStarted) >= time.Hour {
+		b.LastHourBytes = 0
+		b.LastHourStarted = now
+	}
+
+	if now.Sub(b.LastDayStarted) >= 24*time.Hour {
+		b.LastDayBytes = 0
+		b.LastDayStarted = now
+	}
+
+	if !now.Before(b.LastMonthStarted.AddDate(0, 1, 0)) {
+		b.LastMonthBytes = 0
+		b.LastMonthStarted = now
+	}
+
+	return b
+}
+
+// Add rolls any expired window and adds n bytes to every window
+func (b BytesMeta) Add(n int64, now time.Time) BytesMeta {
+	b = b.Rolled(now)
+	b.LastHourBytes += n
+	b.LastDayBytes += n
+	b.LastMonthBytes += n
+
+	return b
+}
+
+// RecordBytesUploaded adds n bytes to the upload counters of the user with the provided ID
+func (c *Client) RecordBytesUploaded(id string, n int64) (BytesMeta, error) {
+	defer c.observe("RecordBytesUploaded")()
+
+	return c.addBytes(id, bytesUploadedField, n)
+}
+
+// RecordBytesDownloaded adds n bytes to the download counters of the user with the provided ID
+func (c *Client) RecordBytesDownloaded(id string, n int64) (BytesMeta, error) {
+	defer c.observe("RecordBytesDownloaded")()
+
+	return c.addBytes(id, bytesDownloadedField, n)
+}
+
+// GetUsage returns the user's transfer counters with expired windows reset
+func (c *Client) GetUsage(id string) (*Usage, error) {
+	defer c.observe("GetUsage")()
+
+	u, err := c.unobserved().GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	return &Usage{
+		BytesUploaded:   u.BytesUploaded.Rolled(now),
+		BytesDownloaded: u.BytesDownloaded.Rolled(now),
+	}, nil
+}
+
+// addBytes rolls and increments a BytesMeta field. The update only applies if the stored
+// counters are unchanged since they were read, so concurrent writers never lose bytes.
+func (c *Client) addBytes(id, field string, n int64) (BytesMeta, error) {
+	for i := 0; i < maxUsageRetries; i++ {
+		u := &User{}
+		if err := c.users.FindId(id).Select(bson.M{field: 1}).One(u); err != nil {
+			return BytesMeta{}, err
+		}
+
+		current := u.BytesUploaded
+		if field == bytesDownloadedField {
+			current = u.BytesDownloaded
+		}
+
+		next := current.Add(n, time.Now().UTC())
+
+		selector := bson.M{"_id": id, field: current}
+		if current == (BytesMeta{}) {
+			// users created before accounting existed may not have the field at all
+			selector = bson.M{"_id": id, "$or": []bson.M{{field: current}, {field: bson.M{"$exists": false}}}}
+		}
+
+		err := c.users.Update(selector, bson.M{"$set": bson.M{field: next}})
+		if err == mgo.ErrNotFound {
+			continue
+		}
+
+		return next, err
+	}
+
+	return BytesMeta{}, ErrUsageConflict
+}
@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesMetaAdd(t *testing.T) {
+	now := time.Date(2018, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		meta     BytesMeta
+		bytes    int64
+		expected BytesMeta
+	}{
+		{
+			name:  "empty counters start every window",
+			meta:  BytesMeta{},
+			bytes: 10,
+			expected: BytesMeta{
+				LastHourBytes: 10, LastHourStarted: now,
+				LastDayBytes: 10, LastDayStarted: now,
+				LastMonthBytes: 10, LastMonthStarted: now,
+			},
+		},
+		{
+			name: "active windows accumulate",
+			meta: BytesMeta{
+				LastHourBytes: 5, LastHourStarted: now.Add(-time.Minute),
+				LastDayBytes: 50, LastDayStarted: now.Add(-time.Hour),
+				LastMonthBytes: 500, LastMonthStarted: now.AddDate(0, 0, -1),
+			},
+			bytes: 10,
+			expected: BytesMeta{
+				LastHourBytes: 15, LastHourStarted: now.Add(-time.Minute),
+				LastDayBytes: 60, LastDayStarted: now.Add(-time.Hour),
+				LastMonthBytes: 510, LastMonthStarted: now.AddDate(0, 0, -1),
+			},
+		},
+		{
+			name: "expired hour and day reset while month accumulates",
+			meta: BytesMeta{
+				LastHourBytes: 5, LastHourStarted: now.Add(-2 * time.Hour),
+				LastDayBytes: 50, LastDayStarted: now.Add(-25 * time.Hour),
+				LastMonthBytes: 500, LastMonthStarted: now.AddDate(0, 0, -2),
+			},
+			bytes: 10,
+			expected: BytesMeta{
+				LastHourBytes: 10, LastHourStarted: now,
+				LastDayBytes: 10, LastDayStarted: now,
+				LastMonthBytes: 510, LastMonthStarted: now.AddDate(0, 0, -2),
+			},
+		},
+		{
+			name: "expired month resets",
+			meta: BytesMeta{
+				LastMonthBytes: 500, LastMonthStarted: now.AddDate(0, -1, 0),
+			},
+			bytes: 10,
+			expected: BytesMeta{
+				LastHourBytes: 10, LastHourStarted: now,
+				LastDayBytes: 10, LastDayStarted: now,
+				LastMonthBytes: 10, LastMonthStarted: now,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.meta.Add(c.bytes, now), c.name)
+	}
+}
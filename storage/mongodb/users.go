@@ -1,45 +1,57 @@
 package mongodb
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/mail"
+	"strings"
 	"time"
 
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
+
+	"github.com/coyle/bridge/storage/password"
 )
 
 var (
-	// ErrInvalidID is returned when the user ID is not in compliance with RFC 5322
+	// ErrInvalidID is returned when the user email is not in compliance with RFC 5322
 	ErrInvalidID = errors.New("invalid id format")
+	// ErrInvalidCredentials is returned when a user email and password do not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrEmailExists is returned when another user already has the email address
+	ErrEmailExists = errors.New("email already in use")
+	// ErrNoPendingEmail is returned when confirming an email change that was never requested
+	ErrNoPendingEmail = errors.New("no pending email change")
 )
 
 // User defines the user schema
 type User struct {
-	ID                string      `bson:"_id" json:"id,omitempty"`
-	UUID              string      `json:"uuid,omitempty"`
-	Hashpass          string      `json:"hashpass,omitempty"`
-	Activated         bool        `json:"activated"`
-	Deactivated       bool        `json:"deactivated"`
-	IsFreeTier        bool        `json:"isFreeTier"`
-	Activator         string      `json:"activator,omitempty"`
-	Deactivator       string      `json:"deactivator,omitempty"`
-	Created           time.Time   `json:"created"`
-	BytesUploaded     BytesMeta   `json:"bytesUploaded,omitempty"`
-	BytesDownloaded   BytesMeta   `json:"bytesDownloaded,omitempty"`
-	PaymentProcessors []string    `json:"paymentProcessors,omitempty"`
-	ReferralPartner   string      `json:"referralPartner,omitempty"`
-	Preferences       Preferences `json:"preferences,omitempty"`
-	Resetter          string      `json:"resetter,omitempty"`
-}
-
-// Preferences contains all user preferences
-type Preferences struct {
-	DNT bool `json:"dnt"`
+	ID                string             `bson:"_id" json:"id,omitempty"`
+	Email             string             `json:"email,omitempty"`
+	PendingEmail      string             `json:"pendingEmail,omitempty"`
+	UUID              string             `json:"uuid,omitempty"`
+	Hashpass          string             `json:"hashpass,omitempty"`
+	HashAlgorithm     string             `json:"hashAlgorithm,omitempty"`
+	Activated         bool               `json:"activated"`
+	Deactivated       bool               `json:"deactivated"`
+	DeactivatedAt     time.Time          `json:"deactivatedAt,omitempty"`
+	IsFreeTier        bool               `json:"isFreeTier"`
+	IsAdmin           bool               `json:"isAdmin,omitempty"`
+	Activator         string             `json:"activator,omitempty"`   // Deprecated: replaced by UserToken
+	Deactivator       string             `json:"deactivator,omitempty"` // Deprecated: replaced by UserToken
+	Created           time.Time          `json:"created"`
+	BytesUploaded     BytesMeta          `json:"bytesUploaded,omitempty"`
+	BytesDownloaded   BytesMeta          `json:"bytesDownloaded,omitempty"`
+	PaymentProcessors []PaymentProcessor `json:"paymentProcessors,omitempty"`
+	ReferralPartner   string             `json:"referralPartner,omitempty"`
+	Preferences       Preferences        `json:"preferences,omitempty"`
+	TOTPEnabled       bool               `json:"totpEnabled,omitempty"`
+	TOTPSecret        string             `json:"-"`
+	RecoveryCodes     []string           `json:"-"`
+	TOTPLastStep      int64              `json:"-"`
+	TOTPAttempts      int                `json:"-"`
+	TOTPAttemptedAt   time.Time          `json:"-"`
+	Resetter          string             `json:"resetter,omitempty"` // Deprecated: replaced by UserToken
 }
 
 // BytesMeta contains metadata about the data uploaded/downloaded
@@ -52,8 +64,12 @@ type BytesMeta struct {
 	LastMonthStarted time.Time `json:"lastMonthStarted"`
 }
 
-// CreateUser initalizes and saves a new user in the Users collection
+// CreateUser initalizes and saves a new user in the Users collection. The user is keyed by
+// their UUID. The Hashpass provided is treated as the user's password and is hashed before
+// it is stored.
 func (c *Client) CreateUser(u User) (User, error) {
+	defer c.observe("CreateUser")()
+
 	zeroTime := time.Time{}
 	if u.Created == zeroTime {
 		u.Created = time.Now().UTC()
@@ -62,75 +78,235 @@ func (c *Client) CreateUser(u User) (User, error) {
 	if u.UUID == "" {
 		u.UUID = uuid.New().String()
 	}
+	u.ID = u.UUID
 
-	if _, err := mail.ParseAddress(u.ID); err != nil {
+	if _, err := mail.ParseAddress(u.Email); err != nil {
 		return User{}, ErrInvalidID
 	}
 
-	err := c.users.Insert(&u)
+	if err := c.emailAvailable(u.Email); err != nil {
+		return User{}, err
+	}
+
+	hash, algorithm, err := password.Hash(u.Hashpass)
+	if err != nil {
+		return User{}, err
+	}
+	u.Hashpass, u.HashAlgorithm = hash, algorithm
+
+	err = c.users.Insert(&u)
 
 	return u, err
 }
 
 // GetUser queries for a user by their ID
 func (c *Client) GetUser(id string) (*User, error) {
+	defer c.observe("GetUser")()
+
 	u := &User{}
 	err := c.users.Find(bson.M{"_id": id}).One(u)
 	return u, err
 }
 
-// GetUserByToken queries for a user by their activator token
-func (c *Client) GetUserByToken(name, token string) (*User, error) {
+// GetUserByEmail queries for a user by their email address
+func (c *Client) GetUserByEmail(email string) (*User, error) {
+	defer c.observe("GetUserByEmail")()
+
 	u := &User{}
-	err := c.users.Find(bson.M{name: token}).One(u)
+	err := c.users.Find(bson.M{"email": email}).One(u)
 	return u, err
 }
 
-// ActivateUser flips the activate flag on the user model with the provided ID
-func (c *Client) ActivateUser(id string) error {
-	return c.users.UpdateId(id, bson.M{"$set": bson.M{"activated": true, "activator": nil}})
+// LookupUser queries for a user by either their ID or, for clients that predate UUID keys,
+// their email address
+func (c *Client) LookupUser(key string) (*User, error) {
+	defer c.observe("LookupUser")()
+
+	if strings.Contains(key, "@") {
+		return c.unobserved().GetUserByEmail(key)
+	}
+
+	return c.unobserved().GetUser(key)
+}
+
+// AuthenticateUser returns the user if the password matches their stored hash. Hashes stored
+// with a legacy algorithm are replaced with a salted hash on success.
+func (c *Client) AuthenticateUser(email, p string) (*User, error) {
+	defer c.observe("AuthenticateUser")()
+
+	u, err := c.unobserved().GetUserByEmail(email)
+	if err == mgo.ErrNotFound {
+		password.VerifyDummy(p)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := password.Verify(u.HashAlgorithm, u.Hashpass, p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if password.NeedsUpgrade(u.HashAlgorithm) {
+		if err := c.setPassword(u.ID, p, bson.M{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return u, nil
 }
 
-// DeactivateUser sets the deactivator to a randomly generated hex string
-func (c *Client) DeactivateUser(id string) error {
-	b := make([]byte, 256)
-	rand.Read(b)
+// ActivateUser flips the activate flag on the user model with the provided ID. Activating a
+// deactivated user during the grace period cancels the deactivation so it is not purged.
+func (c *Client) ActivateUser(id string) error {
+	defer c.observe("ActivateUser")()
 
-	return c.users.UpdateId(id, bson.M{"$set": bson.M{"deactivator": hex.EncodeToString(b)}})
+	return c.users.UpdateId(id, bson.M{
+		"$set":   bson.M{"activated": true, "activator": nil, "deactivated": false},
+		"$unset": bson.M{"deactivatedat": ""},
+	})
 }
 
-// ConfirmUserDeactivation sets the deactivator to a randomly generated hex string
+// ConfirmUserDeactivation flags the user as deactivated and records when so the account can
+// be purged once the grace period has passed
 func (c *Client) ConfirmUserDeactivation(id string) error {
-	b := make([]byte, 256)
-	rand.Read(b)
-	return c.users.UpdateId(id, bson.M{"$set": bson.M{"deactivated": true, "activated": false, "activator": hex.EncodeToString(b)}})
-}
+	defer c.observe("ConfirmUserDeactivation")()
 
-// CreatePasswordResetToken generates a random hex string and saves to the user document
-func (c *Client) CreatePasswordResetToken(id string) (string, error) {
-	b := make([]byte, 256)
-	rand.Read(b)
-	err := c.users.UpdateId(id, bson.M{"$set": bson.M{"resetter": hex.EncodeToString(b)}})
-	return string(b), err
+	return c.users.UpdateId(id, bson.M{"$set": bson.M{
+		"deactivated":   true,
+		"deactivatedat": time.Now().UTC(),
+		"activated":     false,
+		"activator":     nil,
+		"deactivator":   nil,
+	}})
 }
 
 // ResetPassword hashes the users new password and updates the document
 func (c *Client) ResetPassword(id, p string) error {
-	h := sha256.New()
-	h.Write([]byte(p))
+	defer c.observe("ResetPassword")()
+
+	return c.setPassword(id, p, bson.M{"resetter": nil})
+}
+
+// RequestEmailChange validates the new address and stores it until the user confirms it. It
+// returns the token to send to the new address, which only confirms that address.
+func (c *Client) RequestEmailChange(id, email string) (string, error) {
+	defer c.observe("RequestEmailChange")()
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return "", ErrInvalidID
+	}
+
+	if err := c.emailAvailable(email); err != nil {
+		return "", err
+	}
 
-	return c.users.UpdateId(id, bson.M{"$set": bson.M{"resetter": "", "hashpass": fmt.Sprintf("%x", h.Sum(nil))}})
+	if err := c.users.UpdateId(id, bson.M{"$set": bson.M{"pendingemail": email}}); err != nil {
+		return "", err
+	}
+
+	return c.issueUserToken(UserToken{User: id, Purpose: PurposeEmail, Email: email})
+}
+
+// ConfirmEmailChange consumes an email change token and replaces the user's email with their
+// pending email. A token sent for an address the user has since replaced with another pending
+// address is rejected with ErrInvalidToken.
+func (c *Client) ConfirmEmailChange(token string) (*User, error) {
+	defer c.observe("ConfirmEmailChange")()
+
+	t, err := c.consumeUserToken(PurposeEmail, token)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := c.unobserved().GetUser(t.User)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.PendingEmail == "" {
+		return nil, ErrNoPendingEmail
+	}
+	if u.PendingEmail != t.Email {
+		return nil, ErrInvalidToken
+	}
+
+	// the address may have been claimed since the change was requested
+	if err := c.emailAvailable(u.PendingEmail); err != nil {
+		return nil, err
+	}
+
+	err = c.users.Update(bson.M{"_id": u.ID, "pendingemail": t.Email}, bson.M{"$set": bson.M{"email": t.Email, "pendingemail": nil}})
+	if err == mgo.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u.Email, u.PendingEmail = u.PendingEmail, ""
+
+	return u, nil
+}
+
+// emailAvailable returns ErrEmailExists if any user already has the email address
+func (c *Client) emailAvailable(email string) error {
+	cnt, err := c.users.Find(bson.M{"email": email}).Count()
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return ErrEmailExists
+	}
+
+	return nil
+}
+
+// setPassword hashes the password and stores it along with any additional fields
+func (c *Client) setPassword(id, p string, fields bson.M) error {
+	hash, algorithm, err := password.Hash(p)
+	if err != nil {
+		return err
+	}
+
+	fields["hashpass"] = hash
+	fields["hashalgorithm"] = algorithm
+
+	return c.users.UpdateId(id, bson.M{"$set": fields})
 }
 
-// UserToView returns a user object with private fields hidden
-func UserToView(u *User) *User {
-	return &User{
+// UserView is the user as shown to clients. It leaves out credentials, tokens, and usage
+// counters, which are kept to the server.
+type UserView struct {
+	UUID              string             `json:"uuid,omitempty"`
+	Activated         bool               `json:"activated"`
+	IsFreeTier        bool               `json:"isFreeTier"`
+	Created           time.Time          `json:"created"`
+	PaymentProcessors []PaymentProcessor `json:"paymentProcessors,omitempty"`
+	ReferralPartner   string             `json:"referralPartner,omitempty"`
+	Preferences       Preferences        `json:"preferences,omitempty"`
+	TOTPEnabled       bool               `json:"totpEnabled,omitempty"`
+}
+
+// UserToView returns the view of a user shown to clients
+func UserToView(u *User) *UserView {
+	var processors []PaymentProcessor
+	for _, p := range u.PaymentProcessors {
+		processors = append(processors, p.Redacted())
+	}
+
+	return &UserView{
 		UUID:              u.UUID,
 		Activated:         u.Activated,
 		IsFreeTier:        u.IsFreeTier,
 		Created:           u.Created,
-		PaymentProcessors: u.PaymentProcessors,
+		PaymentProcessors: processors,
 		ReferralPartner:   u.ReferralPartner,
 		Preferences:       u.Preferences,
+		TOTPEnabled:       u.TOTPEnabled,
 	}
 }
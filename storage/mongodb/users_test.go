@@ -1,11 +1,23 @@
 package mongodb
 
-// import (
-// 	"testing"
+import (
+	"testing"
 
-// 	"github.com/stretchr/testify/assert"
-// )
+	"github.com/stretchr/testify/assert"
+)
 
-// func TestCreate(t *testing.T) {
-// 	assert.NotNil(t, nil)
-// }
+func TestUserToView(t *testing.T) {
+	u := TestUser(true)
+	u.PaymentProcessors = []PaymentProcessor{
+		{Name: "fake", Default: true, Customer: "cus_123", Description: "card ending 4242"},
+	}
+
+	v := UserToView(u)
+
+	assert.Equal(t, u.UUID, v.UUID)
+	assert.Len(t, v.PaymentProcessors, 1)
+	assert.Equal(t, "fake", v.PaymentProcessors[0].Name)
+	assert.True(t, v.PaymentProcessors[0].Default)
+	assert.Equal(t, "card ending 4242", v.PaymentProcessors[0].Description)
+	assert.Empty(t, v.PaymentProcessors[0].Customer)
+}
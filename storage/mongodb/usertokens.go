@@ -0,0 +1,156 @@
+package mongodb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// PurposeActivation tokens confirm a user's email address
+	PurposeActivation = "activation"
+	// PurposeDeactivation tokens confirm a user's request to delete their account
+	PurposeDeactivation = "deactivation"
+	// PurposeReset tokens allow a user to choose a new password
+	PurposeReset = "reset"
+	// PurposeEmail tokens confirm a user's new email address
+	PurposeEmail = "email"
+)
+
+var (
+	// ErrInvalidToken is returned when a one-time token is unknown, expired, or already used
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrInvalidPurpose is returned when a one-time token is requested for an unknown purpose
+	ErrInvalidPurpose = errors.New("invalid token purpose")
+
+	userTokenTTLs = map[string]time.Duration{
+		PurposeActivation:   7 * 24 * time.Hour,
+		PurposeDeactivation: 24 * time.Hour,
+		PurposeReset:        time.Hour,
+		PurposeEmail:        24 * time.Hour,
+	}
+)
+
+// UserToken defines the one-time token schema in the usertokens collection. Only a hash of
+// the token is stored so the collection can not be used to take over accounts.
+type UserToken struct {
+	ID      string `bson:"_id" json:"-"`
+	User    string `json:"user"`
+	Purpose string `json:"purpose"`
+	// Email is the address a PurposeEmail token was sent to, and the only one it confirms
+	Email   string    `bson:",omitempty" json:"email,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// IssueUserToken generates a one-time token for the user and purpose. The returned hex token
+// is the only copy and must be delivered to the user.
+func (c *Client) IssueUserToken(user, purpose string) (string, error) {
+	defer c.observe("IssueUserToken")()
+
+	return c.issueUserToken(UserToken{User: user, Purpose: purpose})
+}
+
+// issueUserToken fills in the ID and lifetime of the token and saves it. Tokens previously
+// issued to the user for the same purpose are revoked, so only the latest email works.
+func (c *Client) issueUserToken(t UserToken) (string, error) {
+	ttl, ok := userTokenTTLs[t.Purpose]
+	if !ok {
+		return "", ErrInvalidPurpose
+	}
+
+	if _, err := c.userTokens.RemoveAll(bson.M{"user": t.User, "purpose": t.Purpose}); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now().UTC()
+	t.ID = hashToken(token)
+	t.Created = now
+	t.Expires = now.Add(ttl)
+
+	return token, c.userTokens.Insert(&t)
+}
+
+// ConsumeUserToken atomically removes an unexpired token for the purpose and returns its user
+func (c *Client) ConsumeUserToken(purpose, token string) (*User, error) {
+	defer c.observe("ConsumeUserToken")()
+
+	t, err := c.consumeUserToken(purpose, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.unobserved().GetUser(t.User)
+}
+
+// PeekUserToken returns the user an unexpired token for the purpose was issued to, leaving
+// the token in place for ConsumeUserToken
+func (c *Client) PeekUserToken(purpose, token string) (*User, error) {
+	defer c.observe("PeekUserToken")()
+
+	t := &UserToken{}
+	err := c.userTokens.Find(bson.M{
+		"_id":     hashToken(token),
+		"purpose": purpose,
+		"expires": bson.M{"$gt": time.Now().UTC()},
+	}).One(t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.unobserved().GetUser(t.User)
+}
+
+// consumeUserToken atomically removes an unexpired token for the purpose and returns it
+func (c *Client) consumeUserToken(purpose, token string) (*UserToken, error) {
+	t := &UserToken{}
+	_, err := c.userTokens.Find(bson.M{
+		"_id":     hashToken(token),
+		"purpose": purpose,
+		"expires": bson.M{"$gt": time.Now().UTC()},
+	}).Apply(mgo.Change{Remove: true}, t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+
+	return t, err
+}
+
+// SweepExpiredTokens removes expired one-time and bucket operation tokens and returns how many were removed
+func (c *Client) SweepExpiredTokens() (int, error) {
+	defer c.observe("SweepExpiredTokens")()
+
+	expired := bson.M{"expires": bson.M{"$lte": time.Now().UTC()}}
+
+	info, err := c.userTokens.RemoveAll(expired)
+	if err != nil {
+		return 0, err
+	}
+	removed := info.Removed
+
+	info, err = c.tokens.RemoveAll(expired)
+	if err != nil {
+		return removed, err
+	}
+
+	return removed + info.Removed, nil
+}
+
+func hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
@@ -0,0 +1,107 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailChangeTokenConfirmsOnlyItsAddress(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	first, err := c.RequestEmailChange(u.ID, "first-"+u.Email)
+	assert.NoError(t, err)
+	second, err := c.RequestEmailChange(u.ID, "second-"+u.Email)
+	assert.NoError(t, err)
+
+	_, err = c.ConfirmEmailChange(first)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	changed, err := c.ConfirmEmailChange(second)
+	assert.NoError(t, err)
+	assert.Equal(t, "second-"+u.Email, changed.Email)
+
+	stored, err := c.GetUser(u.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second-"+u.Email, stored.Email)
+	assert.Empty(t, stored.PendingEmail)
+}
+
+func TestIssueUserTokenRevokesOlder(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	first, err := c.IssueUserToken(u.ID, PurposeReset)
+	assert.NoError(t, err)
+	activation, err := c.IssueUserToken(u.ID, PurposeActivation)
+	assert.NoError(t, err)
+	second, err := c.IssueUserToken(u.ID, PurposeReset)
+	assert.NoError(t, err)
+
+	_, err = c.ConsumeUserToken(PurposeReset, first)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = c.ConsumeUserToken(PurposeReset, second)
+	assert.NoError(t, err)
+	_, err = c.ConsumeUserToken(PurposeActivation, activation)
+	assert.NoError(t, err, "tokens for other purposes are kept")
+}
+
+func TestMigrateLegacyTokens(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(false)
+	u.Activator = "legacy-activator-" + u.UUID
+	u.Resetter = "legacy-resetter-" + u.UUID
+	assert.NoError(t, c.users.Insert(u))
+
+	_, err := c.MigrateLegacyTokens()
+	assert.NoError(t, err)
+	_, err = c.MigrateLegacyTokens()
+	assert.NoError(t, err, "the migration can be repeated")
+
+	stored, err := c.GetUser(u.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.Activator)
+	assert.Empty(t, stored.Resetter)
+
+	reset, err := c.ConsumeUserToken(PurposeReset, u.Resetter)
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, reset.ID)
+
+	_, err = c.ConsumeUserToken(PurposeActivation, u.Activator)
+	assert.NoError(t, err)
+}
+
+func TestPeekUserTokenLeavesToken(t *testing.T) {
+	c := testClient(t)
+	defer c.Close()
+
+	u := TestUser(true)
+	_, err := c.CreateUser(*u)
+	assert.NoError(t, err)
+
+	token, err := c.IssueUserToken(u.ID, PurposeReset)
+	assert.NoError(t, err)
+
+	peeked, err := c.PeekUserToken(PurposeReset, token)
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, peeked.ID)
+
+	_, err = c.PeekUserToken(PurposeActivation, token)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	consumed, err := c.ConsumeUserToken(PurposeReset, token)
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, consumed.ID)
+}
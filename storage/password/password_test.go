@@ -0,0 +1,67 @@
+package password
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	h, algorithm, err := Hash("password")
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmBcrypt, algorithm)
+	assert.NotEqual(t, "password", h)
+
+	again, _, err := Hash("password")
+	assert.NoError(t, err)
+	assert.NotEqual(t, h, again, "hashes should be salted")
+
+	ok, err := Verify(algorithm, h, "password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = Verify(algorithm, h, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	// the original bridge stored the hex SHA-256 the client sent, which is also what the
+	// client sends to authenticate
+	stored := fmt.Sprintf("%x", sha256.Sum256([]byte("password")))
+
+	_, err := Verify(AlgorithmLegacy, stored, stored)
+	assert.Equal(t, ErrUnwrapped, err, "unwrapped values are never compared")
+
+	h, algorithm, err := Wrap(stored)
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmBcryptLegacy, algorithm)
+	assert.NotContains(t, h, stored)
+
+	cases := []struct {
+		name     string
+		password string
+		expected bool
+	}{
+		{"credential the client sends", stored, true},
+		{"plain password", "password", false},
+		{"empty password", "", false},
+	}
+
+	for _, c := range cases {
+		ok, err := Verify(algorithm, h, c.password)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.expected, ok, c.name)
+	}
+
+	assert.True(t, NeedsUpgrade(AlgorithmLegacy))
+	assert.True(t, NeedsUpgrade(AlgorithmBcryptLegacy))
+	assert.False(t, NeedsUpgrade(AlgorithmBcrypt))
+}
+
+func TestVerifyUnknownAlgorithm(t *testing.T) {
+	_, err := Verify("md5", "hash", "password")
+	assert.Equal(t, ErrUnknownAlgorithm, err)
+}
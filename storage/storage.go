@@ -8,21 +8,38 @@ import (
 var (
 	// ErrInvalidPublicKey is returned when a public key is not in the correct format
 	ErrInvalidPublicKey = errors.New("invalid public key")
+	// ErrInvalidPartner is returned when a partner is missing a name or has a revenue share outside 0-100
+	ErrInvalidPartner = errors.New("invalid partner")
+	// ErrPartnerExists is returned when a partner with the same name already exists
+	ErrPartnerExists = errors.New("partner already exists")
 )
 
 // Partner defines the partner schema in the partners collection
 type Partner struct {
-	ID       string    `json:"_id"`
+	ID       string    `bson:"_id" json:"_id"`
 	Name     string    `json:"name"`
-	RevShare int       `json:"revShareTotalPercentage"`
+	RevShare int       `bson:"revShareTotalPercentage" json:"revShareTotalPercentage"`
 	Created  time.Time `json:"created"`
 }
 
+// Validate ensures the partner has a name and a revenue share percentage between 0 and 100
+func (p *Partner) Validate() error {
+	if p.Name == "" || p.RevShare < 0 || p.RevShare > 100 {
+		return ErrInvalidPartner
+	}
+
+	return nil
+}
+
 // DB is the contract that all databases will need to adhere to
 type DB interface {
 }
 
 // PartnerC is the interface defining methods needed to interact with the partner collection
 type PartnerC interface {
-	GetPartner(ID string) (*Partner, error)
+	GetPartner(name string) (*Partner, error)
+	GetPartnerByID(id string) (*Partner, error)
+	ListPartners() ([]Partner, error)
+	CreatePartner(p Partner) (*Partner, error)
+	UpdatePartner(p Partner) error
 }